@@ -1,13 +1,18 @@
 package app_settings
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math/big"
+	"net"
 	"net/rpc"
 	"os"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/dan-sherwin/go-app-settings/db"
@@ -25,6 +30,28 @@ type (
 		RpcSocketPathToListRunningSettings string
 		KongVars                           *kong.Vars
 		TableName                          string
+		// Encryption enables encryption at rest of setting values.
+		Encryption *EncryptionOptions
+		// Env enables overriding settings from environment variables.
+		Env *EnvOptions
+		// ConfigFiles lists JSON (.json), dotenv (.env) or Java properties (.properties) files of baseline
+		// values keyed by setting name. They apply above the defaults and below the saved values; later
+		// files override earlier ones.
+		ConfigFiles []string
+		// ConfigWatchInterval polls ConfigFiles at this interval and reloads the settings when one changes.
+		// Zero disables the watcher. Call Close to stop it.
+		ConfigWatchInterval time.Duration
+		// OnError receives errors from background tasks such as the config file watcher. Nil logs them.
+		OnError func(error)
+		// HistoryRetention bounds the history table that records every change of a saved value.
+		HistoryRetention HistoryRetention
+		// DBWatchInterval polls the settings table at this interval and applies values added, changed or
+		// removed by other processes sharing the database. Zero disables the watcher. Call Close to stop it.
+		DBWatchInterval time.Duration
+		// HookTimeout bounds each run of a SetFunc and of an OnChange callback. A hook that outlasts it fails
+		// the change, or is reported to OnError for callbacks, but keeps running in the background since Go
+		// cannot stop it; the next SetFunc of the setting waits for it. Zero waits for hooks indefinitely.
+		HookTimeout time.Duration
 	}
 	SettingsDef struct {
 		Logging struct {
@@ -33,30 +60,94 @@ type (
 		Settings SettingsCommand `cmd:"" help:"Settings" group:"App Settings"`
 	}
 	SettingsCommand struct {
-		List   SettingsListCommand   `cmd:"" help:"List settings"`
-		Save   SettingsSaveCommand   `cmd:"" help:"Save settings"`
-		Set    SettingsSaveCommand   `cmd:"" help:"Alias for save"`
-		Remove SettingsRemoveCommand `cmd:"" help:"Remove settings"`
-		Unset  SettingsRemoveCommand `cmd:"" help:"Alias for remove"`
+		// Registry selects the registry these commands operate on; nil means the default registry.
+		Registry *Registry `kong:"-"`
+
+		List     SettingsListCommand     `cmd:"" help:"List settings"`
+		Save     SettingsSaveCommand     `cmd:"" help:"Save settings"`
+		Set      SettingsSaveCommand     `cmd:"" help:"Alias for save"`
+		Remove   SettingsRemoveCommand   `cmd:"" help:"Remove settings"`
+		Unset    SettingsRemoveCommand   `cmd:"" help:"Alias for remove"`
+		Reset    SettingsResetCommand    `cmd:"" help:"Reset settings to their defaults"`
+		Get      SettingsGetCommand      `cmd:"" help:"Show the value of a setting"`
+		Rekey    SettingsRekeyCommand    `cmd:"" help:"Re-encrypt saved settings under a new key"`
+		History  SettingsHistoryCommand  `cmd:"" help:"Show the change history of settings"`
+		Rollback SettingsRollbackCommand `cmd:"" help:"Restore settings to an earlier value from their history"`
+		Export   SettingsExportCommand   `cmd:"" help:"Print saved settings as JSON, dotenv or properties"`
+		Import   SettingsImportCommand   `cmd:"" help:"Save settings from a JSON, dotenv or properties file"`
+		Diff     SettingsDiffCommand     `cmd:"" help:"Show the settings whose values differ between two sources"`
+
+		Complete SettingsCompleteCommand `cmd:"" hidden:"" help:"Print shell completion candidates"`
 	}
 
 	SettingsListDefaultsCommand struct{}
 	SettingsListSavedCommand    struct{}
 	SettingsListRunningCommand  struct{}
-	SettingsListActiveCommand   struct{}
-	SettingsListCommand         struct {
+	SettingsListActiveCommand   struct {
+		Scope []string `help:"List the values settings resolve to in these scopes, most specific first, such as user:bob,tenant:acme" sep:"," placeholder:"KIND:ID"`
+	}
+	SettingsListCommand struct {
 		Defaults SettingsListDefaultsCommand `cmd:"" help:"List default settings"`
 		Saved    SettingsListSavedCommand    `cmd:"" help:"List saved settings"`
 		Running  SettingsListRunningCommand  `cmd:"" help:"List running settings"`
-		Active   SettingsListActiveCommand   `cmd:"" help:"List active settings"`
+		Active   SettingsListActiveCommand   `cmd:"" help:"List active settings, or with --scope the values they resolve to in those scopes"`
 	}
 
 	SettingsSaveCommand struct {
-		Setting string `arg:"" help:"Setting to set" required:""`
-		Value   string `arg:"" help:"Value to set" required:""`
+		Setting   string   `arg:"" help:"Setting to set, or the first of several NAME=VALUE pairs saved together" required:""`
+		Value     string   `arg:"" help:"Value to set, or the second NAME=VALUE pair" required:""`
+		Pairs     []string `arg:"" help:"Further NAME=VALUE pairs" optional:""`
+		Reason    string   `help:"Reason recorded in the settings history"`
+		IfVersion *int64   `help:"Only save if the saved value is still at this version from 'list saved' (0: not saved)" placeholder:"N"`
+		Scope     string   `help:"Save an override for this scope, such as tenant:acme, instead of the global value" placeholder:"KIND:ID"`
 	}
 	SettingsRemoveCommand struct {
 		Setting string `arg:"" help:"Setting to remove" required:""`
+		Reason  string `help:"Reason recorded in the settings history"`
+		Scope   string `help:"Remove the override of this scope instead of the global value" placeholder:"KIND:ID"`
+	}
+	SettingsHistoryCommand struct {
+		Setting string `arg:"" help:"Setting to show the history of" optional:""`
+		Since   string `help:"Only show changes since a duration ago (24h) or a time (2006-01-02 or RFC 3339)"`
+	}
+	SettingsRollbackCommand struct {
+		Setting string `arg:"" help:"Setting to roll back" optional:""`
+		To      string `help:"History version, time (2006-01-02 or RFC 3339) or duration ago (1h) to roll back to; default undoes the latest change"`
+		All     bool   `help:"Roll back every setting to its value at --to"`
+		Reason  string `help:"Reason recorded in the settings history"`
+	}
+	SettingsExportCommand struct {
+		Format          string `help:"Output format" enum:"json,env,properties" default:"json"`
+		IncludeDefaults bool   `help:"Also export the default value of settings that are not saved"`
+		IncludeHidden   bool   `help:"Also export hidden settings"`
+		Reveal          bool   `help:"Export the values of sensitive settings instead of skipping them"`
+	}
+	SettingsImportCommand struct {
+		File    string `arg:"" help:"JSON, .env or .properties file to import" type:"existingfile"`
+		DryRun  bool   `help:"Print the changes without saving them"`
+		Merge   bool   `help:"Keep saved settings missing from the file (default)" xor:"mode"`
+		Replace bool   `help:"Remove saved settings missing from the file, except sensitive ones" xor:"mode"`
+		Reason  string `help:"Reason recorded in the settings history"`
+	}
+	SettingsDiffCommand struct {
+		From string `help:"Values to compare: defaults, saved, running, active or a config file" default:"saved"`
+		To   string `help:"Values to compare with: defaults, saved, running, active or a config file" default:"running"`
+	}
+	SettingsRekeyCommand struct {
+		NewKeyFile string `help:"File holding the new key" type:"path"`
+		NewKeyEnv  string `help:"Environment variable holding the new key"`
+	}
+	SettingsGetCommand struct {
+		Setting string `arg:"" help:"Setting to show" required:""`
+		Reveal  bool   `help:"Show the value of a sensitive setting"`
+	}
+	SettingsCompleteCommand struct {
+		Args []string `arg:"" optional:"" passthrough:"" help:"Words following 'settings'"`
+	}
+	SettingsResetCommand struct {
+		Setting string `arg:"" help:"Setting to reset" optional:""`
+		All     bool   `help:"Reset every setting"`
+		Reason  string `help:"Reason recorded in the settings history"`
 	}
 	Setting struct {
 		SetFunc           func(string) error
@@ -65,6 +156,20 @@ type (
 		Name              string
 		Description       string
 		Hidden            bool
+		// ParseFunc checks that a value converts to the setting's type without applying it. Validate runs it
+		// first, so values that never reach SetFunc, such as scoped overrides, are checked too. The typed
+		// register helpers set it; a setting without one cannot be overridden per scope.
+		ParseFunc func(string) error
+		// Validators run against the string value before SetFunc and before anything is persisted.
+		Validators []Validator
+		// AllowedValues lists the accepted values of an enum setting for display and completion.
+		AllowedValues []string
+		// Sensitive masks the value in every output path; only `settings get --reveal` shows it.
+		Sensitive bool
+		// Encrypted stores the value encrypted when SettingsOptions.Encryption is configured.
+		Encrypted bool
+		// number converts a value of a numeric setting for the Min, Max and Between rules.
+		number func(string) (*big.Rat, error)
 	}
 
 	SettingReceiver interface {
@@ -75,42 +180,184 @@ type (
 	}
 )
 
-// defaultSettings holds a list of default application settings of type AppSetting from the models package.
-// settings contains a list of customizable settings defined by the Setting type.
-// socketPath represents the file path for the application socket, initialized as an empty string.
-var (
-	defaultSettings = []*models.AppSetting{}
-	settings        = []*Setting{}
-	settingsMu      sync.RWMutex
-	socketPath      = ""
-)
+// Registry is an independent settings store: it owns its registered settings, the database table they
+// are persisted in, the RPC endpoint that reports running values and the state used by the Kong commands.
+// The package-level functions operate on a shared default registry.
+type Registry struct {
+	mu              sync.RWMutex
+	settings        []*Setting
+	defaultSettings []*models.AppSetting
+	socketPath      string
+	store           *db.Store
+	rpcServer       *rpc.Server
+	// serving is the socket server started by ServeRPC.
+	serving *rpcListener
+	cipher  *valueCipher
+	env     *EnvOptions
+	// envOverrides maps the settings overridden by the environment to the variable that set them.
+	envOverrides map[string]string
+	sources      map[string]provenance
+	configFiles  []string
+	fileValues   map[string]layerValue
+	flagValues   map[string]string
+	retention    HistoryRetention
+	onError      func(error)
+	hookTimeout  time.Duration
+	// setFuncSlots lets one SetFunc per setting run at a time; see runSetFunc.
+	setFuncSlots map[string]chan struct{}
+	// scoped holds the scoped overrides by scope and then by setting name.
+	scoped map[string]map[string]string
+	// stop is closed by Close to end the background tasks started by setup.
+	stop chan struct{}
+
+	subMu     sync.Mutex
+	subs      map[string][]*subscription
+	nextSubID uint64
+}
+
+var defaultRegistry = NewRegistry()
+
+// NewRegistry returns an empty Registry. Register settings on it and then call Setup or SetupWithDB.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultRegistry returns the registry used by the package-level functions.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
 
 // Setup initializes the application with the provided settings file and options.
 // It configures the database, sets up the RPC socket if specified, merges Kong variables, and retrieves application settings.
 // Returns an error if any initialization step fails.
 func Setup(settingsFileName string, options SettingsOptions) error {
-	if err := db.DBInitWithTable(settingsFileName, options.tableName()); err != nil {
+	return SetupContext(context.Background(), settingsFileName, options)
+}
+
+// SetupContext is Setup with a context bounding the database work and the hooks run while loading the saved
+// values. The background watchers are not tied to ctx; call Close to stop them.
+func SetupContext(ctx context.Context, settingsFileName string, options SettingsOptions) error {
+	store, err := db.NewStoreContext(ctx, settingsFileName, options.tableName())
+	if err != nil {
 		return err
 	}
-	return setup(options)
+	return setupDefault(ctx, store, options)
 }
 
 func SetupWithDB(gormDB *gorm.DB, options SettingsOptions) error {
-	if err := db.DBInitWithDB(gormDB, options.tableName()); err != nil {
+	return SetupWithDBContext(context.Background(), gormDB, options)
+}
+
+// SetupWithDBContext is SetupWithDB with a context. See SetupContext.
+func SetupWithDBContext(ctx context.Context, gormDB *gorm.DB, options SettingsOptions) error {
+	store, err := db.NewStoreWithDBContext(ctx, gormDB, options.tableName())
+	if err != nil {
+		return err
+	}
+	return setupDefault(ctx, store, options)
+}
+
+// setupDefault keeps the package-level db variables pointed at the default registry's store and, for
+// applications that serve rpc.DefaultServer themselves, publishes the running settings there as well.
+func setupDefault(ctx context.Context, store *db.Store, options SettingsOptions) error {
+	db.UseStore(store)
+	if options.RpcSocketPathToListRunningSettings != "" {
+		// Registering again after a repeated Setup fails harmlessly: the service already serves the default
+		// registry.
+		_ = rpc.RegisterName(rpcServiceName, &settingsService{registry: defaultRegistry})
+	}
+	return defaultRegistry.setup(ctx, store, options)
+}
+
+// Setup opens the settings file for this registry and loads the saved values into its settings.
+func (r *Registry) Setup(settingsFileName string, options SettingsOptions) error {
+	return r.SetupContext(context.Background(), settingsFileName, options)
+}
+
+// SetupContext is Setup with a context bounding the database work and the hooks run while loading the saved
+// values. The background watchers are not tied to ctx; call Close to stop them.
+func (r *Registry) SetupContext(ctx context.Context, settingsFileName string, options SettingsOptions) error {
+	store, err := db.NewStoreContext(ctx, settingsFileName, options.tableName())
+	if err != nil {
+		return err
+	}
+	return r.setup(ctx, store, options)
+}
+
+// SetupWithDB stores this registry's settings in a table of an existing database.
+func (r *Registry) SetupWithDB(gormDB *gorm.DB, options SettingsOptions) error {
+	return r.SetupWithDBContext(context.Background(), gormDB, options)
+}
+
+// SetupWithDBContext is SetupWithDB with a context. See Registry.SetupContext.
+func (r *Registry) SetupWithDBContext(ctx context.Context, gormDB *gorm.DB, options SettingsOptions) error {
+	store, err := db.NewStoreWithDBContext(ctx, gormDB, options.tableName())
+	if err != nil {
 		return err
 	}
-	return setup(options)
+	return r.setup(ctx, store, options)
 }
 
-func setup(options SettingsOptions) error {
+func (r *Registry) setup(ctx context.Context, store *db.Store, options SettingsOptions) error {
+	var c *valueCipher
+	if options.Encryption != nil {
+		var err error
+		if c, err = newValueCipher(options.Encryption.Key, options.Encryption.All); err != nil {
+			return fmt.Errorf("configure encryption: %w", err)
+		}
+	}
+	r.mu.Lock()
+	r.store = store
+	r.cipher = c
+	r.env = options.Env
+	r.configFiles = options.ConfigFiles
+	r.onError = options.OnError
+	r.hookTimeout = options.HookTimeout
+	r.retention = options.HistoryRetention
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
 	if options.RpcSocketPathToListRunningSettings != "" {
-		socketPath = options.RpcSocketPathToListRunningSettings
-		rpc.Register(&SettingsListRunningCommand{})
+		r.socketPath = options.RpcSocketPathToListRunningSettings
+		r.rpcServer = rpc.NewServer()
+		if err := r.rpcServer.RegisterName(rpcServiceName, &settingsService{registry: r}); err != nil {
+			r.mu.Unlock()
+			return err
+		}
 	}
+	r.mu.Unlock()
 	if options.KongVars != nil {
-		utilities.MergeInto(*options.KongVars, SettingsVars())
+		utilities.MergeInto(*options.KongVars, r.SettingsVars())
+	}
+	// Stamps and rows are taken before the first load so a change made while loading is picked up by the
+	// watchers.
+	stamps := configFileStamps(options.ConfigFiles)
+	var rows map[rowKey]string
+	if options.DBWatchInterval > 0 {
+		var err error
+		if rows, err = storedRows(store.WithContext(ctx)); err != nil {
+			return err
+		}
+	}
+	if err := r.RetrieveAppSettingsContext(ctx); err != nil {
+		return err
 	}
-	return RetrieveAppSettings()
+	watchFiles := len(options.ConfigFiles) > 0 && options.ConfigWatchInterval > 0
+	if !watchFiles && options.DBWatchInterval <= 0 {
+		return nil
+	}
+	stop := make(chan struct{})
+	r.mu.Lock()
+	r.stop = stop
+	r.mu.Unlock()
+	if watchFiles {
+		go r.watchConfigFiles(options.ConfigFiles, stamps, options.ConfigWatchInterval, stop)
+	}
+	if options.DBWatchInterval > 0 {
+		go r.watchDB(store, rows, options.DBWatchInterval, stop)
+	}
+	return nil
 }
 
 func (o SettingsOptions) tableName() string {
@@ -120,27 +367,68 @@ func (o SettingsOptions) tableName() string {
 	return o.TableName
 }
 
-// GetSetting retrieves a `Setting` by its name from the global list `settings`.
-// If no match is found, it returns `nil`.
+// RPCServer returns the server publishing this registry's running settings, or nil when no
+// RpcSocketPathToListRunningSettings was configured. ServeRPC serves it on the socket; applications with their
+// own listener can serve it themselves instead.
+func (r *Registry) RPCServer() *rpc.Server {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rpcServer
+}
+
+func (r *Registry) getStore() (*db.Store, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.store == nil {
+		return nil, errors.New("settings store is not initialized; call Setup first")
+	}
+	return r.store, nil
+}
+
+// storeContext returns the store with its queries bound to ctx.
+func (r *Registry) storeContext(ctx context.Context) (*db.Store, error) {
+	store, err := r.getStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.WithContext(ctx), nil
+}
+
+// GetSetting retrieves a `Setting` by its name from the default registry.
+// If no match is found, it returns an error.
 func GetSetting(name string) (*Setting, error) {
-	settingsMu.RLock()
-	defer settingsMu.RUnlock()
-	if !slices.ContainsFunc(settings, func(s *Setting) bool {
-		return s.Name == name
-	}) {
-		return nil, fmt.Errorf("Setting %s not found", name)
+	return defaultRegistry.GetSetting(name)
+}
+
+// GetSetting retrieves a `Setting` by its name. If no match is found, it returns an error.
+func (r *Registry) GetSetting(name string) (*Setting, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if setting := r.lookup(name); setting != nil {
+		return setting, nil
 	}
-	if setting := settings[slices.IndexFunc(settings, func(s *Setting) bool {
+	return nil, fmt.Errorf("Setting %s not found", name)
+}
+
+// lookup finds a registered setting by name. The caller must hold r.mu.
+func (r *Registry) lookup(name string) *Setting {
+	if i := slices.IndexFunc(r.settings, func(s *Setting) bool {
 		return s.Name == name
-	})]; setting == nil {
-		return nil, fmt.Errorf("Setting %s not found", name)
-	} else {
-		return setting, nil
+	}); i >= 0 {
+		return r.settings[i]
 	}
+	return nil
 }
 
-func getCLISetting(name string) (*Setting, error) {
-	setting, err := GetSetting(name)
+// snapshot returns a copy of the registered settings that can be used without holding r.mu.
+func (r *Registry) snapshot() []*Setting {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.settings)
+}
+
+func (r *Registry) getCLISetting(name string) (*Setting, error) {
+	setting, err := r.GetSetting(name)
 	if err != nil {
 		return nil, err
 	}
@@ -150,180 +438,438 @@ func getCLISetting(name string) (*Setting, error) {
 	return setting, nil
 }
 
+// ProvideRegistry supplies the registry the settings subcommands operate on. It returns the Registry
+// field when set, so several registries can be mounted as separate Kong commands, and the default registry otherwise.
+func (c *SettingsCommand) ProvideRegistry() (*Registry, error) {
+	if c.Registry != nil {
+		return c.Registry, nil
+	}
+	return defaultRegistry, nil
+}
+
 // Run removes the specified application setting if it exists, otherwise returns an error.
-// It identifies the setting by name, deletes it from the database, and handles any errors encountered during the operation.
+// It deletes the saved value from the database and restores the setting's default in memory.
 // On success, it prints a confirmation message.
-func (c *SettingsRemoveCommand) Run() error {
-	setting, err := getCLISetting(c.Setting)
+func (c *SettingsRemoveCommand) Run(r *Registry) error {
+	ctx := context.Background()
+	setting, err := r.getCLISetting(c.Setting)
 	if err != nil {
 		return printAndReturnErr(err)
 	}
-	_, err = db.AppSetting.Where(db.AppSetting.Key.Eq(setting.Name)).Delete()
-	if err != nil {
-		return printAndReturnErr(fmt.Errorf("Error deleting setting %s: %w", c.Setting, err))
+	if c.Scope != "" {
+		if err := r.deleteScoped(ctx, setting.Name, c.Scope, c.Reason); err != nil {
+			return printAndReturnErr(err)
+		}
+		fmt.Printf("Setting %s removed from scope %s\n", c.Setting, c.Scope)
+		return r.applyToDaemon(ctx, setting.Name)
+	}
+	if err := r.resetSetting(ctx, setting.Name, c.Reason); err != nil {
+		return printAndReturnErr(err)
 	}
 	fmt.Printf("Setting %s removed\n", c.Setting)
+	return r.applyToDaemon(ctx, setting.Name)
+}
+
+// Run resets a single setting, or every visible setting with --all, to its default value.
+func (c *SettingsResetCommand) Run(r *Registry) error {
+	ctx := context.Background()
+	if c.All == (c.Setting != "") {
+		return printAndReturnErr(errors.New("specify either a setting name or --all"))
+	}
+	names := []string{c.Setting}
+	if c.All {
+		names = names[:0]
+		for _, s := range r.snapshot() {
+			if !s.Hidden {
+				names = append(names, s.Name)
+			}
+		}
+	}
+	for _, name := range names {
+		setting, err := r.getCLISetting(name)
+		if err != nil {
+			return printAndReturnErr(err)
+		}
+		if err := r.resetSetting(ctx, setting.Name, c.Reason); err != nil {
+			return printAndReturnErr(err)
+		}
+		fmt.Printf("Setting %s reset to default\n", setting.Name)
+	}
+	return r.applyToDaemon(ctx, names...)
+}
+
+// ResetSetting resets a setting of the default registry. See Registry.ResetSetting.
+func ResetSetting(name string) error {
+	return defaultRegistry.ResetSetting(name)
+}
+
+// ResetSettingContext resets a setting of the default registry. See Registry.ResetSettingContext.
+func ResetSettingContext(ctx context.Context, name string) error {
+	return defaultRegistry.ResetSettingContext(ctx, name)
+}
+
+// ResetSetting deletes the saved value of a setting and reapplies the default recorded by
+// RetrieveAppSettings. Subscribers are notified as for any other change. A setting overridden by the
+// environment or the command line keeps that value and one set by a config file returns to the file's value.
+func (r *Registry) ResetSetting(name string) error {
+	return r.resetSetting(context.Background(), name, "")
+}
+
+// ResetSettingContext is ResetSetting with a context bounding the database work and the hooks.
+func (r *Registry) ResetSettingContext(ctx context.Context, name string) error {
+	return r.resetSetting(ctx, name, "")
+}
+
+func (r *Registry) resetSetting(ctx context.Context, name, reason string) error {
+	setting, err := r.GetSetting(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := r.defaultValue(setting.Name); !ok {
+		return fmt.Errorf("no default recorded for setting %s", setting.Name)
+	}
+	if err := r.saveSetting(ctx, setting, savedState{}, reason); err != nil {
+		return fmt.Errorf("Error resetting setting %s: %w", setting.Name, err)
+	}
 	return nil
 }
 
-// SetSetting updates the value of a specified setting by its name.
-// Converts the provided value to a string and applies it using the setting's SetFunc.
-// Saves the updated setting to the database and returns an error if any operation fails.
+// baseValue returns the value a setting runs with when it has no saved value: its config file value or
+// defaultValue. It returns false when the environment or the command line pins the setting, which then
+// keeps its running value.
+func (r *Registry) baseValue(setting *Setting, defaultValue string) (layerValue, bool) {
+	if r.pinned(setting.Name) {
+		return layerValue{}, false
+	}
+	if v, ok := r.fileValue(setting.Name); ok {
+		return v, true
+	}
+	return layerValue{value: defaultValue, source: SourceDefault}, true
+}
+
+// pinned reports whether the environment or the command line overrides the named setting, so changes to
+// its saved value do not reach the running value.
+func (r *Registry) pinned(name string) bool {
+	if _, overridden := r.envOverride(name); overridden {
+		return true
+	}
+	_, overridden := r.flagValue(name)
+	return overridden
+}
+
+// defaultValue returns the value the named setting had when RetrieveAppSettings recorded the defaults.
+func (r *Registry) defaultValue(name string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, d := range r.defaultSettings {
+		if d.Key == name {
+			return d.Value, true
+		}
+	}
+	return "", false
+}
+
+// SetSetting updates the value of a specified setting in the default registry.
 func SetSetting(settingName string, value any) error {
-	setting, err := GetSetting(settingName)
+	return defaultRegistry.SetSetting(settingName, value)
+}
+
+// SetSettingContext updates a setting of the default registry. See Registry.SetSettingContext.
+func SetSettingContext(ctx context.Context, settingName string, value any) error {
+	return defaultRegistry.SetSettingContext(ctx, settingName, value)
+}
+
+// SetSetting updates the value of a specified setting by its name.
+// Converts the provided value to a string, validates it and saves it to the database, then applies it using
+// the setting's SetFunc. When the SetFunc fails or panics, the previous saved value is written back, so the
+// running and the saved value agree. A SetFunc that times out keeps running and may still apply the value,
+// so then the new value stays saved and the running value is indeterminate until the SetFunc returns.
+func (r *Registry) SetSetting(settingName string, value any) error {
+	return r.SetSettingContext(context.Background(), settingName, value)
+}
+
+// SetSettingContext is SetSetting with a context bounding the database transaction, the SetFunc and the
+// change callbacks. When ctx ends while the SetFunc runs, the SetFunc is given up on like one that times out:
+// the new value stays saved.
+func (r *Registry) SetSettingContext(ctx context.Context, settingName string, value any) error {
+	setting, err := r.GetSetting(settingName)
 	if err != nil {
 		return err
 	}
+	if err := r.checkNotOverridden(setting); err != nil {
+		return err
+	}
 	valueStr, err := setting.ValueToString(value)
 	if err != nil {
 		return err
 	}
-	if err := setting.SetFunc(valueStr); err != nil {
+	return r.saveSetting(ctx, setting, savedState{value: valueStr, saved: true}, "")
+}
+
+// saveSetting changes the saved state of one setting and applies it with commitSaved.
+func (r *Registry) saveSetting(ctx context.Context, setting *Setting, state savedState, reason string) error {
+	store, err := r.getStore()
+	if err != nil {
 		return err
 	}
-	if err := db.AppSetting.Save(&models.AppSetting{
-		Key:   setting.Name,
-		Value: valueStr,
-	}); err != nil {
-		return err
+	op := OpSave
+	if !state.saved {
+		op = OpDelete
 	}
-	return nil
+	return r.commitSaved(ctx, store, []savedChange{{setting: setting, state: state}}, op, reason)
 }
 
 // Run executes the command to update a specific application setting with a provided value and persists it in the database.
-func (c *SettingsSaveCommand) Run() error {
-	setting, err := getCLISetting(c.Setting)
+func (c *SettingsSaveCommand) Run(r *Registry) error {
+	ctx := context.Background()
+	if c.Scope != "" {
+		return c.runScoped(ctx, r)
+	}
+	if strings.Contains(c.Setting, "=") {
+		return c.runPairs(ctx, r)
+	}
+	if len(c.Pairs) > 0 {
+		return printAndReturnErr(errors.New("use either <setting> <value> or NAME=VALUE pairs"))
+	}
+	setting, err := r.getCLISetting(c.Setting)
 	if err != nil {
 		return printAndReturnErr(err)
 	}
+	if err := r.checkNotOverridden(setting); err != nil {
+		return printAndReturnErr(err)
+	}
 	valueStr, err := setting.ValueToString(c.Value)
 	if err != nil {
 		return printAndReturnErr(err)
 	}
-	err = setting.SetFunc(valueStr)
+	if c.IfVersion != nil {
+		err = r.setIfUnchanged(ctx, setting, *c.IfVersion, valueStr, c.Reason)
+	} else {
+		err = r.saveSetting(ctx, setting, savedState{value: valueStr, saved: true}, c.Reason)
+	}
+	if err != nil {
+		return printAndReturnErr(err)
+	}
+	fmt.Printf("Setting %s saved to %s\n", c.Setting, setting.display(c.Value))
+	return r.applyToDaemon(ctx, setting.Name)
+}
+
+// pairs parses the NAME=VALUE arguments of the command into values by name and the names in order.
+func (c *SettingsSaveCommand) pairs(r *Registry) (map[string]any, []string, error) {
+	args := append([]string{c.Setting, c.Value}, c.Pairs...)
+	values := map[string]any{}
+	names := []string{}
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return nil, nil, fmt.Errorf("%s: expected NAME=VALUE", arg)
+		}
+		if _, dup := values[name]; dup {
+			return nil, nil, fmt.Errorf("setting %s given more than once", name)
+		}
+		if _, err := r.getCLISetting(name); err != nil {
+			return nil, nil, err
+		}
+		values[name] = value
+		names = append(names, name)
+	}
+	return values, names, nil
+}
+
+// runPairs saves several NAME=VALUE pairs as one change with SetSettings.
+func (c *SettingsSaveCommand) runPairs(ctx context.Context, r *Registry) error {
+	if c.IfVersion != nil {
+		return printAndReturnErr(errors.New("--if-version applies to a single setting"))
+	}
+	values, names, err := c.pairs(r)
 	if err != nil {
 		return printAndReturnErr(err)
 	}
-	if err := db.AppSetting.Save(&models.AppSetting{
-		Key:   setting.Name,
-		Value: valueStr,
-	}); err != nil {
+	if err := r.setSettings(ctx, values, c.Reason); err != nil {
 		return printAndReturnErr(err)
 	}
-	fmt.Printf("Setting %s saved to %s\n", c.Setting, c.Value)
-	return nil
+	for _, name := range names {
+		setting, _ := r.GetSetting(name)
+		fmt.Printf("Setting %s saved to %s\n", name, setting.display(values[name].(string)))
+	}
+	return r.applyToDaemon(ctx, names...)
 }
 
 // Run connects to a Unix socket, retrieves running application settings via RPC, processes them, and displays them. It returns an error if the connection fails or if settings retrieval is unsuccessful.
-func (c *SettingsListRunningCommand) Run() error {
+func (c *SettingsListRunningCommand) Run(r *Registry) error {
 	var runningSettings []models.AppSetting
 
-	client, err := rpc.Dial("unix", socketPath)
+	ctx := context.Background()
+	client, err := r.dialDaemon(ctx)
 	if err != nil {
-		return printAndReturnErr(fmt.Errorf("Error connecting to socket: %w", err))
+		return printAndReturnErr(err)
 	}
+	defer client.Close()
 
-	err = client.Call("SettingsListRunningCommand.GetRunningSettings", &struct{}{}, &runningSettings)
+	err = callDaemon(ctx, client, "GetRunningSettings", &struct{}{}, &runningSettings)
 	if err != nil {
 		return printAndReturnErr(fmt.Errorf("Error getting running settings: %w", err))
 	}
 
-	var buf []*models.AppSetting
-	for _, s := range runningSettings {
-		buf = append(buf, &s)
-	}
-	printSettings(buf)
+	printSettings(appSettingValuesToPointers(runningSettings))
 	return nil
 }
 
+// rpcServiceName is the net/rpc service name the running settings are published under.
+const rpcServiceName = "SettingsListRunningCommand"
+
+// settingsService is the net/rpc receiver a Registry publishes on its socket.
+type settingsService struct {
+	registry *Registry
+}
+
+// dialDaemon connects to the RPC socket of the running application.
+func (r *Registry) dialDaemon(ctx context.Context) (*rpc.Client, error) {
+	r.mu.RLock()
+	socketPath := r.socketPath
+	r.mu.RUnlock()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to socket: %w", err)
+	}
+	return rpc.NewClient(conn), nil
+}
+
+// callDaemon calls method of the settings service on client, giving up when ctx is done. The client is closed
+// when the call is abandoned, since net/rpc cannot cancel a single call.
+func callDaemon(ctx context.Context, client *rpc.Client, method string, args, reply any) error {
+	call := client.Go(rpcServiceName+"."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		_ = client.Close()
+		return ctx.Err()
+	}
+}
+
 // GetRunningSettings retrieves the current running application settings and maps them into a slice of AppSetting.
 // The result is assigned to the provided data pointer. Returns an error if the operation fails.
-func (c *SettingsListRunningCommand) GetRunningSettings(_ *struct{}, data *[]models.AppSetting) error {
+func (s *settingsService) GetRunningSettings(_ *struct{}, data *[]models.AppSetting) error {
 	runningSettings := []models.AppSetting{}
-	settingsMu.RLock()
-	defer settingsMu.RUnlock()
-	for _, s := range settings {
+	registry := s.registry
+	for _, s := range registry.snapshot() {
 		if s.Hidden {
 			continue
 		}
 		runningSettings = append(runningSettings, models.AppSetting{
 			Key:         s.Name,
-			Value:       s.GetFunc(),
-			Description: s.Description,
+			Value:       s.display(s.GetFunc()),
+			Description: s.describe(),
+			Source:      string(registry.source(s)),
 		})
 	}
 	*data = runningSettings
 	return nil
 }
 
+// GetRunningSettings reports the running settings of the default registry.
+func (c *SettingsListRunningCommand) GetRunningSettings(args *struct{}, data *[]models.AppSetting) error {
+	return (&settingsService{registry: defaultRegistry}).GetRunningSettings(args, data)
+}
+
 // Run executes the command to display default application settings in a sorted table format. It uses the printSettings function to handle the output of pre-defined settings. Returns nil upon successful execution.
-func (c *SettingsListDefaultsCommand) Run() error {
-	printSettings(visibleAppSettings(defaultSettings))
+func (c *SettingsListDefaultsCommand) Run(r *Registry) error {
+	r.mu.RLock()
+	defaults := r.defaultSettings
+	r.mu.RUnlock()
+	settings := r.visibleAppSettings(defaults)
+	for _, s := range settings {
+		s.Source = string(SourceDefault)
+	}
+	printSettings(settings)
 	return nil
 }
 
 // Run executes the command to retrieve and display saved settings. It fetches settings from the database, handles errors, and prints the retrieved settings to the console. Returns an error if there is an issue during the retrieval process.
-func (c *SettingsListSavedCommand) Run() error {
-	s, err := db.AppSetting.Find()
+func (c *SettingsListSavedCommand) Run(r *Registry) error {
+	store, err := r.getStore()
+	if err != nil {
+		return printAndReturnErr(err)
+	}
+	s, err := r.loadValues(store)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return fmt.Errorf("Error getting saved settings: %w", err)
 	}
 	savedSettings := []models.AppSetting{}
 	for _, as := range s {
-		setting, err := GetSetting(as.Key)
+		setting, err := r.GetSetting(as.Key)
 		if err != nil || setting.Hidden {
 			continue
 		}
-		as.Description = setting.Description
+		as.Value = setting.display(as.Value)
+		as.Description = setting.describe()
+		as.Source = string(SourceDB)
 		savedSettings = append(savedSettings, *as)
 	}
-	printSettings(appSettingValuesToPointers(savedSettings))
+	printSavedSettings(appSettingValuesToPointers(savedSettings))
 	return nil
 }
 
-func (c *SettingsListActiveCommand) Run() error {
-	s, err := db.AppSetting.Find()
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return fmt.Errorf("Error getting saved settings: %w", err)
+// Run displays the values the settings are running with in this process and where each one came from.
+func (c *SettingsListActiveCommand) Run(r *Registry) error {
+	if len(c.Scope) > 0 {
+		return c.runScoped(r)
 	}
-	activeSettings := visibleAppSettings(defaultSettings)
-	for _, ss := range s {
-		setting, err := GetSetting(ss.Key)
-		if err != nil || setting.Hidden {
+	activeSettings := []*models.AppSetting{}
+	for _, s := range r.snapshot() {
+		if s.Hidden {
 			continue
 		}
-		for _, as := range activeSettings {
-			if as.Key == ss.Key {
-				as.Value = ss.Value
-				as.Description = setting.Description
-			}
-		}
+		activeSettings = append(activeSettings, &models.AppSetting{
+			Key:         s.Name,
+			Value:       s.display(s.GetFunc()),
+			Description: s.describe(),
+			Source:      string(r.source(s)),
+		})
 	}
 	printSettings(activeSettings)
 	return nil
 }
 
-// printSettings displays a sorted list of application settings in a tabular format showing their keys and values.
+// printSettings displays a sorted list of application settings in a tabular format showing their keys, values
+// and the source of each value.
 func printSettings(settings []*models.AppSetting) {
 	slices.SortFunc(settings, func(a, b *models.AppSetting) int {
 		return strings.Compare(a.Key, b.Key)
 	})
 	table := tablewriter.NewWriter(os.Stdout)
-	table.Header([]string{"Setting", "Value", "Description"})
+	table.Header([]string{"Setting", "Value", "Source", "Description"})
 	for _, s := range settings {
-		table.Append([]string{s.Key, s.Value, s.Description})
+		table.Append([]string{s.Key, s.Value, s.Source, s.Description})
 	}
 	table.Render()
 }
 
-func visibleAppSettings(source []*models.AppSetting) []*models.AppSetting {
+// printSavedSettings is printSettings with the version of each saved value, for `settings save --if-version`.
+func printSavedSettings(settings []*models.AppSetting) {
+	slices.SortFunc(settings, func(a, b *models.AppSetting) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header([]string{"Setting", "Value", "Version", "Source", "Description"})
+	for _, s := range settings {
+		table.Append([]string{s.Key, s.Value, strconv.FormatInt(s.Version, 10), s.Source, s.Description})
+	}
+	table.Render()
+}
+
+func (r *Registry) visibleAppSettings(source []*models.AppSetting) []*models.AppSetting {
 	buf := []*models.AppSetting{}
 	for _, s := range source {
-		setting, err := GetSetting(s.Key)
+		setting, err := r.GetSetting(s.Key)
 		if err != nil || setting.Hidden {
 			continue
 		}
 		cp := *s
+		cp.Value = setting.display(cp.Value)
+		cp.Description = setting.describe()
 		buf = append(buf, &cp)
 	}
 	return buf
@@ -345,43 +891,109 @@ func (s *Setting) ValueToString(value any) (string, error) {
 	return fmt.Sprintf("%v", value), nil
 }
 
-// SettingsVars constructs a kong.Vars map by iterating through all settings, retrieving their values using associated getters, and populating the map with setting names as keys and their retrieved values as values.
+// SettingsVars returns the current values of the default registry's settings as kong.Vars.
 func SettingsVars() kong.Vars {
+	return defaultRegistry.SettingsVars()
+}
+
+// SettingsVars constructs a kong.Vars map by iterating through all settings, retrieving their values using associated getters, and populating the map with setting names as keys and their retrieved values as values.
+// Sensitive settings are masked.
+func (r *Registry) SettingsVars() kong.Vars {
 	vars := kong.Vars{}
-	settingsMu.RLock()
-	defer settingsMu.RUnlock()
-	for _, s := range settings {
-		vars[s.Name] = s.GetFunc()
+	for _, s := range r.snapshot() {
+		vars[s.Name] = s.display(s.GetFunc())
 	}
 	return vars
 }
 
-// RetrieveAppSettings fetches application settings from the database and initializes default settings.
-// If database retrieval fails, the application exits with an error.
-// It also updates in-memory settings based on the retrieved values from the database.
+// RetrieveAppSettings loads the saved values of the default registry's settings.
 func RetrieveAppSettings() error {
-	defaultSettings = []*models.AppSetting{}
-	settingsMu.RLock()
-	defer settingsMu.RUnlock()
+	return defaultRegistry.RetrieveAppSettings()
+}
+
+// RetrieveAppSettingsContext loads the saved values of the default registry's settings. See
+// Registry.RetrieveAppSettingsContext.
+func RetrieveAppSettingsContext(ctx context.Context) error {
+	return defaultRegistry.RetrieveAppSettingsContext(ctx)
+}
+
+// RetrieveAppSettings fetches application settings from the database and initializes default settings.
+// It also updates in-memory settings from the config files, the database, the environment variables when
+// SettingsOptions.Env is configured and the command line overrides, in increasing order of precedence.
+func (r *Registry) RetrieveAppSettings() error {
+	return r.RetrieveAppSettingsContext(context.Background())
+}
+
+// RetrieveAppSettingsContext is RetrieveAppSettings with a context bounding the database query and the hooks.
+func (r *Registry) RetrieveAppSettingsContext(ctx context.Context) error {
+	settings := r.snapshot()
+	defaults := make([]*models.AppSetting, 0, len(settings))
 	for _, s := range settings {
-		defaultSettings = append(defaultSettings, &models.AppSetting{
+		// A default is captured once, before any saved value is applied, so later reloads keep the code default.
+		value, recorded := r.defaultValue(s.Name)
+		if !recorded {
+			value = s.GetFunc()
+		}
+		defaults = append(defaults, &models.AppSetting{
 			Key:         s.Name,
-			Value:       s.GetFunc(),
+			Value:       value,
 			Description: s.Description,
 		})
 	}
-	appSettings, err := db.AppSetting.Find()
+	r.mu.Lock()
+	r.defaultSettings = defaults
+	r.mu.Unlock()
+	store, err := r.storeContext(ctx)
+	if err != nil {
+		return err
+	}
+	appSettings, err := r.loadValues(store)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return fmt.Errorf("Error getting app settings: %w", err)
 	}
-	errs := []error{}
-	if appSettings != nil {
-		for _, as := range appSettings {
-			if s, err := GetSetting(as.Key); err == nil {
-				err := s.SetFunc(as.Value)
-				if err != nil {
-					errs = append(errs, fmt.Errorf("Error setting setting %s: %w", as.Key, err))
-				}
+	if err := r.refreshScoped(store); err != nil {
+		return err
+	}
+	// Each setting takes its value from the highest layer that provides one: command line, environment,
+	// database, config files, then the default.
+	values, errs := r.configFileValues()
+	for _, as := range appSettings {
+		if _, err := r.GetSetting(as.Key); err == nil {
+			values[as.Key] = layerValue{value: as.Value, source: SourceDB}
+		}
+	}
+	envValues, err := r.envValues()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	overrides := map[string]string{}
+	for name, v := range envValues {
+		values[name] = v
+		overrides[name] = v.origin
+	}
+	r.mu.Lock()
+	r.envOverrides = overrides
+	for name, value := range r.flagValues {
+		values[name] = layerValue{value: value, source: SourceFlag, origin: "the command line"}
+	}
+	r.mu.Unlock()
+	for _, s := range settings {
+		v, ok := values[s.Name]
+		if !ok {
+			// A value whose layer no longer provides it falls back to the default.
+			if source := r.source(s); source == SourceDefault || source == SourceRuntime {
+				continue
+			}
+			if v.value, ok = r.defaultValue(s.Name); !ok {
+				continue
+			}
+			v.source = SourceDefault
+		}
+		if err := r.apply(ctx, s, v.value, v.source); err != nil {
+			if v.origin != "" {
+				errs = append(errs, fmt.Errorf("Error setting setting %s from %s: %w", s.Name, v.origin, err))
+			} else {
+				errs = append(errs, fmt.Errorf("Error setting setting %s: %w", s.Name, err))
 			}
 		}
 	}
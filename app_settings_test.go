@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/alecthomas/kong"
 	"github.com/dan-sherwin/go-app-settings/db"
 	"github.com/dan-sherwin/go-app-settings/db/models"
 	"github.com/glebarez/sqlite"
@@ -14,12 +15,8 @@ import (
 )
 
 // test helpers
-func resetGlobals() {
-	settingsMu.Lock()
-	defer settingsMu.Unlock()
-	settings = []*Setting{}
-	defaultSettings = []*models.AppSetting{}
-	socketPath = ""
+func resetDefaultRegistry() {
+	defaultRegistry = NewRegistry()
 }
 
 func tempDBPath(t *testing.T) string {
@@ -29,8 +26,8 @@ func tempDBPath(t *testing.T) string {
 }
 
 // Registers a simple string setting bound to the provided pointer
-func registerStringSetting(name string, desc string, prop *string) {
-	RegisterSetting(&Setting{
+func registerStringSetting(r *Registry, name string, desc string, prop *string) {
+	r.RegisterSetting(&Setting{
 		Name:        name,
 		Description: desc,
 		GetFunc:     func() string { return *prop },
@@ -39,12 +36,13 @@ func registerStringSetting(name string, desc string, prop *string) {
 }
 
 func TestGetSetting_FoundAndNotFound(t *testing.T) {
-	resetGlobals()
+	t.Parallel()
+	r := NewRegistry()
 	var foo string
-	registerStringSetting("foo", "Foo setting", &foo)
+	registerStringSetting(r, "foo", "Foo setting", &foo)
 
 	// Found
-	s, err := GetSetting("foo")
+	s, err := r.GetSetting("foo")
 	if err != nil {
 		t.Fatalf("expected to find setting, got err: %v", err)
 	}
@@ -53,16 +51,16 @@ func TestGetSetting_FoundAndNotFound(t *testing.T) {
 	}
 
 	// Not found
-	if _, err := GetSetting("bar"); err == nil {
+	if _, err := r.GetSetting("bar"); err == nil {
 		t.Fatalf("expected error for missing setting")
 	}
 }
 
 func TestSetup_SettingPersistenceAndVars(t *testing.T) {
-	resetGlobals()
+	resetDefaultRegistry()
 	var foo string
 	foo = "defaultFoo"
-	registerStringSetting("foo", "Foo setting", &foo)
+	registerStringSetting(defaultRegistry, "foo", "Foo setting", &foo)
 
 	// Setup with temp DB
 	if err := Setup(tempDBPath(t), SettingsOptions{}); err != nil {
@@ -70,8 +68,8 @@ func TestSetup_SettingPersistenceAndVars(t *testing.T) {
 	}
 
 	// Defaults copied into defaultSettings
-	if len(defaultSettings) != 1 || defaultSettings[0].Key != "foo" || defaultSettings[0].Value != "defaultFoo" {
-		t.Fatalf("defaultSettings not initialized correctly: %#v", defaultSettings)
+	if defaults := defaultRegistry.defaultSettings; len(defaults) != 1 || defaults[0].Key != "foo" || defaults[0].Value != "defaultFoo" {
+		t.Fatalf("defaultSettings not initialized correctly: %#v", defaults)
 	}
 
 	// Set new value and ensure it is saved in DB
@@ -95,23 +93,24 @@ func TestSetup_SettingPersistenceAndVars(t *testing.T) {
 }
 
 func TestRetrieveAppSettings_LoadsFromDB(t *testing.T) {
-	resetGlobals()
+	t.Parallel()
+	r := NewRegistry()
 	var foo string
 	foo = "defaultFoo"
-	registerStringSetting("foo", "Foo setting", &foo)
+	registerStringSetting(r, "foo", "Foo setting", &foo)
 
-	if err := Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
 		t.Fatalf("setup failed: %v", err)
 	}
 
 	// Save different value directly and reload
-	if err := db.AppSetting.Save(&models.AppSetting{Key: "foo", Value: "persisted"}); err != nil {
+	if err := r.store.AppSetting.Save(&models.AppSetting{Key: "foo", Value: "persisted"}); err != nil {
 		t.Fatalf("save failed: %v", err)
 	}
 
 	// Overwrite local value to ensure Retrieve updates it
 	foo = "somethingElse"
-	if err := RetrieveAppSettings(); err != nil {
+	if err := r.RetrieveAppSettings(); err != nil {
 		t.Fatalf("RetrieveAppSettings failed: %v", err)
 	}
 	if foo != "persisted" {
@@ -120,15 +119,16 @@ func TestRetrieveAppSettings_LoadsFromDB(t *testing.T) {
 }
 
 func TestListRunning_GetRunningSettings(t *testing.T) {
-	resetGlobals()
+	t.Parallel()
+	r := NewRegistry()
 	var a, b string
 	a = "A"
 	b = "B"
-	registerStringSetting("a", "A desc", &a)
-	registerStringSetting("b", "B desc", &b)
+	registerStringSetting(r, "a", "A desc", &a)
+	registerStringSetting(r, "b", "B desc", &b)
 
 	// No DB needed for GetRunningSettings
-	cmd := &SettingsListRunningCommand{}
+	cmd := &settingsService{registry: r}
 	var out []models.AppSetting
 	if err := cmd.GetRunningSettings(&struct{}{}, &out); err != nil {
 		t.Fatalf("GetRunningSettings error: %v", err)
@@ -156,23 +156,23 @@ func captureStdout(f func()) string {
 }
 
 func TestListSavedAndActiveCommands_Print(t *testing.T) {
-	resetGlobals()
+	r := NewRegistry()
 	var foo string
 	foo = "default"
-	registerStringSetting("foo", "Foo setting", &foo)
-	if err := Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+	registerStringSetting(r, "foo", "Foo setting", &foo)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
 		t.Fatalf("setup failed: %v", err)
 	}
 
 	// Save value
-	if err := SetSetting("foo", "savedValue"); err != nil {
+	if err := r.SetSetting("foo", "savedValue"); err != nil {
 		t.Fatalf("SetSetting failed: %v", err)
 	}
 
 	// Saved list prints row for saved setting
 	savedCmd := &SettingsListSavedCommand{}
 	out := captureStdout(func() {
-		_ = savedCmd.Run()
+		_ = savedCmd.Run(r)
 	})
 	if !strings.Contains(out, "foo") || !strings.Contains(out, "savedValue") {
 		t.Fatalf("saved list output unexpected: %s", out)
@@ -181,7 +181,7 @@ func TestListSavedAndActiveCommands_Print(t *testing.T) {
 	// Active list should reflect saved value overriding default
 	activeCmd := &SettingsListActiveCommand{}
 	out = captureStdout(func() {
-		_ = activeCmd.Run()
+		_ = activeCmd.Run(r)
 	})
 	if !strings.Contains(out, "foo") || !strings.Contains(out, "savedValue") {
 		t.Fatalf("active list output unexpected: %s", out)
@@ -189,38 +189,42 @@ func TestListSavedAndActiveCommands_Print(t *testing.T) {
 }
 
 func TestRemoveCommand_RemovesFromDB(t *testing.T) {
-	resetGlobals()
+	t.Parallel()
+	r := NewRegistry()
 	var foo string
-	registerStringSetting("foo", "Foo setting", &foo)
-	if err := Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+	registerStringSetting(r, "foo", "Foo setting", &foo)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
 		t.Fatalf("setup failed: %v", err)
 	}
-	if err := SetSetting("foo", "bar"); err != nil {
+	if err := r.SetSetting("foo", "bar"); err != nil {
 		t.Fatalf("SetSetting failed: %v", err)
 	}
 
 	// Remove via command
 	rm := &SettingsRemoveCommand{Setting: "foo"}
-	if err := rm.Run(); err != nil {
+	if err := rm.Run(r); err != nil {
 		t.Fatalf("remove failed: %v", err)
 	}
 
 	// Ensure DB is empty
-	got, err := db.AppSetting.Find()
+	got, err := r.store.AppSetting.Find()
 	if err != nil {
 		t.Fatalf("db find failed: %v", err)
 	}
 	if len(got) != 0 {
 		t.Fatalf("expected empty DB, got: %#v", got)
 	}
+	if foo != "" {
+		t.Fatalf("expected in-memory value to be restored to the default, got %q", foo)
+	}
 }
 
 func TestHiddenSettings_AreNotAvailableThroughCLI(t *testing.T) {
-	resetGlobals()
+	r := NewRegistry()
 	visible := "visible-default"
 	hidden := "hidden-default"
-	RegisterStringSetting("visible", "Visible setting", &visible)
-	RegisterSetting(&Setting{
+	r.RegisterStringSetting("visible", "Visible setting", &visible)
+	r.RegisterSetting(&Setting{
 		Name:        "hidden",
 		Description: "Hidden setting",
 		Hidden:      true,
@@ -231,10 +235,10 @@ func TestHiddenSettings_AreNotAvailableThroughCLI(t *testing.T) {
 		},
 	})
 
-	if err := Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
 		t.Fatalf("setup failed: %v", err)
 	}
-	if err := SetSetting("hidden", "hidden-saved"); err != nil {
+	if err := r.SetSetting("hidden", "hidden-saved"); err != nil {
 		t.Fatalf("programmatic SetSetting for hidden setting failed: %v", err)
 	}
 	if hidden != "hidden-saved" {
@@ -242,14 +246,14 @@ func TestHiddenSettings_AreNotAvailableThroughCLI(t *testing.T) {
 	}
 
 	defaultsOut := captureStdout(func() {
-		_ = (&SettingsListDefaultsCommand{}).Run()
+		_ = (&SettingsListDefaultsCommand{}).Run(r)
 	})
 	if strings.Contains(defaultsOut, "hidden") || !strings.Contains(defaultsOut, "visible") {
 		t.Fatalf("defaults output did not hide hidden setting: %s", defaultsOut)
 	}
 
 	running := []models.AppSetting{}
-	if err := (&SettingsListRunningCommand{}).GetRunningSettings(&struct{}{}, &running); err != nil {
+	if err := (&settingsService{registry: r}).GetRunningSettings(&struct{}{}, &running); err != nil {
 		t.Fatalf("GetRunningSettings failed: %v", err)
 	}
 	for _, s := range running {
@@ -258,56 +262,57 @@ func TestHiddenSettings_AreNotAvailableThroughCLI(t *testing.T) {
 		}
 	}
 
-	if err := (&SettingsSaveCommand{Setting: "hidden", Value: "cli-value"}).Run(); err == nil {
+	if err := (&SettingsSaveCommand{Setting: "hidden", Value: "cli-value"}).Run(r); err == nil {
 		t.Fatalf("expected CLI save for hidden setting to fail")
 	}
-	if err := (&SettingsRemoveCommand{Setting: "hidden"}).Run(); err == nil {
+	if err := (&SettingsRemoveCommand{Setting: "hidden"}).Run(r); err == nil {
 		t.Fatalf("expected CLI remove for hidden setting to fail")
 	}
-	if got := SettingsVars()["hidden"]; got != "hidden-saved" {
+	if got := r.SettingsVars()["hidden"]; got != "hidden-saved" {
 		t.Fatalf("SettingsVars should keep hidden settings available to the app, got %q", got)
 	}
 }
 
 func TestRegisterJSONSetting_SettingPersistenceAndValidation(t *testing.T) {
-	resetGlobals()
+	t.Parallel()
+	r := NewRegistry()
 	type launchLayout struct {
 		ViewMode string `json:"viewMode"`
 		Columns  int    `json:"columns"`
 	}
 	layout := launchLayout{ViewMode: "list", Columns: 1}
-	RegisterJSONSettingWithValidator("layout", "Launch layout", &layout, func(value launchLayout) error {
+	RegisterJSONSettingWithValidatorIn(r, "layout", "Launch layout", &layout, func(value launchLayout) error {
 		if value.Columns < 1 {
 			return os.ErrInvalid
 		}
 		return nil
 	})
 
-	if err := Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
 		t.Fatalf("setup failed: %v", err)
 	}
-	if got := SettingsVars()["layout"]; got != `{"viewMode":"list","columns":1}` {
+	if got := r.SettingsVars()["layout"]; got != `{"viewMode":"list","columns":1}` {
 		t.Fatalf("unexpected default JSON setting: %s", got)
 	}
-	if err := SetSetting("layout", launchLayout{ViewMode: "grid", Columns: 4}); err != nil {
+	if err := r.SetSetting("layout", launchLayout{ViewMode: "grid", Columns: 4}); err != nil {
 		t.Fatalf("SetSetting with struct failed: %v", err)
 	}
 	if layout.ViewMode != "grid" || layout.Columns != 4 {
 		t.Fatalf("layout was not updated from struct value: %#v", layout)
 	}
-	if err := SetSetting("layout", `{"viewMode":"list","columns":2}`); err != nil {
+	if err := r.SetSetting("layout", `{"viewMode":"list","columns":2}`); err != nil {
 		t.Fatalf("SetSetting with JSON string failed: %v", err)
 	}
 	if layout.ViewMode != "list" || layout.Columns != 2 {
 		t.Fatalf("layout was not updated from JSON string: %#v", layout)
 	}
-	if err := SetSetting("layout", `{"viewMode":"grid","columns":0}`); err == nil {
+	if err := r.SetSetting("layout", `{"viewMode":"grid","columns":0}`); err == nil {
 		t.Fatalf("expected validation error for invalid JSON setting")
 	}
 }
 
 func TestSetupWithDB_UsesApplicationDatabase(t *testing.T) {
-	resetGlobals()
+	resetDefaultRegistry()
 	type LaunchItem struct {
 		ID   uint `gorm:"primaryKey"`
 		Name string
@@ -345,9 +350,10 @@ func TestSetupWithDB_UsesApplicationDatabase(t *testing.T) {
 }
 
 func TestSetupWithDB_TableConflictAndCustomTableName(t *testing.T) {
-	resetGlobals()
+	t.Parallel()
+	r := NewRegistry()
 	var foo string
-	RegisterStringSetting("foo", "Foo setting", &foo)
+	r.RegisterStringSetting("foo", "Foo setting", &foo)
 
 	gormDB, err := gorm.Open(sqlite.Open(tempDBPath(t)), &gorm.Config{})
 	if err != nil {
@@ -356,13 +362,139 @@ func TestSetupWithDB_TableConflictAndCustomTableName(t *testing.T) {
 	if err := gormDB.Exec("CREATE TABLE app_settings (id integer primary key, bogus text)").Error; err != nil {
 		t.Fatalf("create conflicting table failed: %v", err)
 	}
-	if err := SetupWithDB(gormDB, SettingsOptions{}); err == nil {
+	if err := r.SetupWithDB(gormDB, SettingsOptions{}); err == nil {
 		t.Fatalf("expected incompatible app_settings table to fail setup")
 	}
-	if err := SetupWithDB(gormDB, SettingsOptions{TableName: "runtime_settings"}); err != nil {
+	if err := r.SetupWithDB(gormDB, SettingsOptions{TableName: "runtime_settings"}); err != nil {
 		t.Fatalf("SetupWithDB with custom table name failed: %v", err)
 	}
 	if !gormDB.Migrator().HasTable("runtime_settings") {
 		t.Fatalf("expected custom settings table")
 	}
 }
+
+func TestRegistries_AreIndependent(t *testing.T) {
+	t.Parallel()
+	gormDB, err := gorm.Open(sqlite.Open(tempDBPath(t)), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db failed: %v", err)
+	}
+	pluginA, pluginB := NewRegistry(), NewRegistry()
+	var a, b string
+	pluginA.RegisterStringSetting("name", "Plugin A name", &a)
+	pluginB.RegisterStringSetting("name", "Plugin B name", &b)
+	if err := pluginA.SetupWithDB(gormDB, SettingsOptions{TableName: "plugin_a_settings"}); err != nil {
+		t.Fatalf("setup A failed: %v", err)
+	}
+	if err := pluginB.SetupWithDB(gormDB, SettingsOptions{TableName: "plugin_b_settings"}); err != nil {
+		t.Fatalf("setup B failed: %v", err)
+	}
+	if err := pluginA.SetSetting("name", "alpha"); err != nil {
+		t.Fatalf("SetSetting A failed: %v", err)
+	}
+	if a != "alpha" || b != "" {
+		t.Fatalf("setting leaked between registries: a=%q b=%q", a, b)
+	}
+	var count int64
+	if err := gormDB.Table("plugin_b_settings").Count(&count).Error; err != nil {
+		t.Fatalf("count plugin B rows failed: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected plugin B table to stay empty, count=%d", count)
+	}
+	if _, err := GetSetting("name"); err == nil {
+		t.Fatalf("registry settings should not be visible through the default registry")
+	}
+}
+
+func TestKongCommands_UseMountedRegistry(t *testing.T) {
+	r := NewRegistry()
+	foo := "default"
+	r.RegisterStringSetting("foo", "Foo setting", &foo)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	var cli struct {
+		Plugin SettingsCommand `cmd:""`
+	}
+	cli.Plugin.Registry = r
+	parser, err := kong.New(&cli)
+	if err != nil {
+		t.Fatalf("kong.New failed: %v", err)
+	}
+	ctx, err := parser.Parse([]string{"plugin", "save", "foo", "bar"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	captureStdout(func() {
+		err = ctx.Run()
+	})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if foo != "bar" {
+		t.Fatalf("expected mounted registry to be updated, got %q", foo)
+	}
+}
+
+func TestResetSetting_RestoresDefault(t *testing.T) {
+	r := NewRegistry()
+	foo := "default"
+	bar := "bar-default"
+	hidden := "hidden-default"
+	r.RegisterStringSetting("foo", "Foo setting", &foo)
+	r.RegisterStringSetting("bar", "Bar setting", &bar)
+	r.RegisterSetting(&Setting{
+		Name:    "hidden",
+		Hidden:  true,
+		GetFunc: func() string { return hidden },
+		SetFunc: func(s string) error { hidden = s; return nil },
+	})
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	for name, value := range map[string]string{"foo": "saved", "bar": "bar-saved", "hidden": "hidden-saved"} {
+		if err := r.SetSetting(name, value); err != nil {
+			t.Fatalf("SetSetting %s failed: %v", name, err)
+		}
+	}
+	// Reloading must not turn saved values into defaults.
+	if err := r.RetrieveAppSettings(); err != nil {
+		t.Fatalf("RetrieveAppSettings failed: %v", err)
+	}
+
+	var changes []string
+	r.OnChange("foo", func(old, new string) { changes = append(changes, old+"->"+new) })
+	if err := r.ResetSetting("foo"); err != nil {
+		t.Fatalf("ResetSetting failed: %v", err)
+	}
+	if foo != "default" {
+		t.Fatalf("expected foo to be restored, got %q", foo)
+	}
+	if len(changes) != 1 || changes[0] != "saved->default" {
+		t.Fatalf("expected one change notification, got %v", changes)
+	}
+
+	captureStdout(func() {
+		if err := (&SettingsResetCommand{All: true}).Run(r); err != nil {
+			t.Errorf("reset --all failed: %v", err)
+		}
+	})
+	if bar != "bar-default" {
+		t.Fatalf("expected bar to be restored, got %q", bar)
+	}
+	if hidden != "hidden-saved" {
+		t.Fatalf("reset --all must not touch hidden settings, got %q", hidden)
+	}
+	rows, err := r.store.AppSetting.Find()
+	if err != nil {
+		t.Fatalf("db find failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Key != "hidden" {
+		t.Fatalf("expected only the hidden row to remain, got %#v", rows)
+	}
+	if err := (&SettingsResetCommand{}).Run(r); err == nil {
+		t.Fatalf("expected error without a setting name or --all")
+	}
+}
@@ -0,0 +1,213 @@
+package app_settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dan-sherwin/go-app-settings/db"
+)
+
+// savedState is the saved value of a setting, or its absence.
+type savedState struct {
+	value string
+	saved bool
+}
+
+// savedChange is a change of the saved state of a setting. A non-nil ifVersion makes the change conditional
+// on the saved value still being at that version.
+type savedChange struct {
+	setting   *Setting
+	state     savedState
+	ifVersion *int64
+}
+
+// savedStates returns the saved state of every registered setting that has a saved value.
+func (r *Registry) savedStates(store *db.Store) (map[string]savedState, error) {
+	rows, err := r.loadValues(store)
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]savedState{}
+	for _, row := range rows {
+		current[row.Key] = savedState{value: row.Value, saved: true}
+	}
+	return current, nil
+}
+
+// commitSaved writes a set of saved changes in one transaction, recording them in the history under op, and
+// then applies the new running values through SetFunc. Every value is validated before anything is written.
+// When a SetFunc fails after the commit, the values already applied are reverted in memory and the previous
+// saved values are written back, so either every change is made or none is. A SetFunc given up on after a
+// timeout or the end of ctx may still apply its value, so then nothing is undone: the new values stay saved,
+// the other settings are applied, and the running value of that setting is indeterminate until its SetFunc
+// returns. Settings pinned by the environment or a flag keep their running value. ctx bounds the transaction
+// and the hooks; the compensating writes run even once it is done.
+func (r *Registry) commitSaved(ctx context.Context, store *db.Store, plan []savedChange, op, reason string) error {
+	for _, p := range plan {
+		if p.state.saved {
+			if err := p.setting.Validate(p.state.value); err != nil {
+				return p.setting.maskError(err)
+			}
+		}
+	}
+	if len(plan) == 0 {
+		return nil
+	}
+	undo := make([]savedChange, 0, len(plan))
+	err := store.WithContext(ctx).Transaction(func(tx *db.Store) error {
+		current, err := r.savedStates(tx)
+		if err != nil {
+			return err
+		}
+		versions, err := r.writeSaved(tx, plan, op, reason)
+		if err != nil {
+			return err
+		}
+		// The undo only applies while the rows are still at the versions written here, so it never
+		// overwrites a writer that committed after them.
+		undo = undo[:0]
+		for i, p := range plan {
+			undo = append(undo, savedChange{setting: p.setting, state: current[p.setting.Name], ifVersion: &versions[i]})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	done, err := r.applySaved(ctx, plan)
+	if err != nil && hookAbandoned(err) {
+		for _, a := range done {
+			if a.change.Old != a.change.New {
+				r.notify(ctx, a.change)
+			}
+		}
+		return fmt.Errorf("%w (the new values stay saved; the setting runs with an indeterminate value until its SetFunc returns)", err)
+	}
+	if err != nil {
+		for i := len(done) - 1; i >= 0; i-- {
+			r.revert(ctx, done[i].setting, done[i].change, done[i].source)
+		}
+		if restoreErr := store.WithContext(context.WithoutCancel(ctx)).Transaction(func(tx *db.Store) error {
+			_, writeErr := r.writeSaved(tx, undo, OpRollback, "undo "+op+": "+err.Error())
+			return writeErr
+		}); restoreErr != nil {
+			return fmt.Errorf("%w; restoring the previous saved values failed: %v", err, restoreErr)
+		}
+		return err
+	}
+	for _, a := range done {
+		if a.change.Old != a.change.New {
+			r.notify(ctx, a.change)
+		}
+	}
+	return nil
+}
+
+// writeSaved writes the saved state of each change inside tx, recording op in the history, and returns the
+// version each saved value is at afterwards, 0 for a removed one.
+func (r *Registry) writeSaved(tx *db.Store, plan []savedChange, op, reason string) ([]int64, error) {
+	versions := make([]int64, len(plan))
+	for i, p := range plan {
+		if p.state.saved {
+			version, err := r.writeVersion(tx, p.setting, p.state.value, op, reason, p.ifVersion)
+			if err != nil {
+				return nil, err
+			}
+			versions[i] = version
+		} else if _, err := r.removeValue(tx, p.setting, op, reason, p.ifVersion); err != nil {
+			return nil, err
+		}
+	}
+	return versions, nil
+}
+
+// appliedChange is a running value changed by applySaved, with the source it replaced.
+type appliedChange struct {
+	setting *Setting
+	change  Change
+	source  Source
+}
+
+// applySaved updates the running values after their saved states changed. It stops at the first SetFunc
+// that fails and returns the changes made until then, unless a SetFunc was given up on before: since that
+// change cannot be undone, the remaining settings are still applied and every failure is returned.
+func (r *Registry) applySaved(ctx context.Context, plan []savedChange) ([]appliedChange, error) {
+	done := []appliedChange{}
+	var abandoned error
+	for _, p := range plan {
+		run := layerValue{value: p.state.value, source: SourceDB}
+		if !p.state.saved || r.pinned(p.setting.Name) {
+			defaultValue, _ := r.defaultValue(p.setting.Name)
+			var ok bool
+			if run, ok = r.baseValue(p.setting, defaultValue); !ok {
+				continue
+			}
+		}
+		source := r.source(p.setting)
+		c, err := r.set(ctx, p.setting, run.value, run.source)
+		if err != nil {
+			if abandoned == nil && !hookAbandoned(err) {
+				return done, err
+			}
+			abandoned = errors.Join(abandoned, err)
+			continue
+		}
+		done = append(done, appliedChange{setting: p.setting, change: c, source: source})
+	}
+	return done, abandoned
+}
+
+// SetSettings updates several settings of the default registry at once. See Registry.SetSettings.
+func SetSettings(values map[string]any) error {
+	return defaultRegistry.SetSettings(values)
+}
+
+// SetSettingsContext updates several settings of the default registry at once. See
+// Registry.SetSettingsContext.
+func SetSettingsContext(ctx context.Context, values map[string]any) error {
+	return defaultRegistry.SetSettingsContext(ctx, values)
+}
+
+// SetSettings saves several settings as one change: every value is converted and validated first, all rows
+// are written in one transaction, and only after it commits are the values applied through SetFunc. If any
+// step fails, no setting changes, neither saved nor in memory, except after a SetFunc times out: see
+// SetSetting.
+func (r *Registry) SetSettings(values map[string]any) error {
+	return r.setSettings(context.Background(), values, "")
+}
+
+// SetSettingsContext is SetSettings with a context bounding the database transaction, the SetFuncs and the
+// change callbacks.
+func (r *Registry) SetSettingsContext(ctx context.Context, values map[string]any) error {
+	return r.setSettings(ctx, values, "")
+}
+
+func (r *Registry) setSettings(ctx context.Context, values map[string]any, reason string) error {
+	store, err := r.getStore()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	plan := make([]savedChange, 0, len(names))
+	for _, name := range names {
+		setting, err := r.GetSetting(name)
+		if err != nil {
+			return err
+		}
+		if err := r.checkNotOverridden(setting); err != nil {
+			return err
+		}
+		valueStr, err := setting.ValueToString(values[name])
+		if err != nil {
+			return fmt.Errorf("setting %s: %w", name, err)
+		}
+		plan = append(plan, savedChange{setting: setting, state: savedState{value: valueStr, saved: true}})
+	}
+	return r.commitSaved(ctx, store, plan, OpSave, reason)
+}
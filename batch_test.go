@@ -0,0 +1,172 @@
+package app_settings
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSetSettings_AllOrNothing(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	port, region := 8080, "eu"
+	var committed bool
+	var mode string
+	r.RegisterIntSetting("http.port", "HTTP port", &port)
+	r.RegisterStringSetting("region", "Region", &region)
+	r.RegisterSetting(&Setting{
+		Name:    "mode",
+		GetFunc: func() string { return mode },
+		SetFunc: func(s string) error {
+			if s == "broken" {
+				return errors.New("cannot switch to broken mode")
+			}
+			// SetFunc runs once the rows are committed.
+			if rows, _ := r.store.AppSetting.Find(); len(rows) > 0 {
+				committed = true
+			}
+			mode = s
+			return nil
+		},
+	})
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	if err := r.SetSettings(map[string]any{"http.port": 9000, "region": "us", "mode": "fast"}); err != nil {
+		t.Fatalf("SetSettings failed: %v", err)
+	}
+	if port != 9000 || region != "us" || mode != "fast" || !committed {
+		t.Fatalf("batch not applied after commit: port=%d region=%q mode=%q committed=%v", port, region, mode, committed)
+	}
+
+	if err := r.SetSettings(map[string]any{"region": "ap", "missing": 1}); err == nil {
+		t.Fatalf("expected unknown setting to fail the batch")
+	}
+	if err := r.SetSettings(map[string]any{"region": "ap", "http.port": "fast"}); err == nil {
+		t.Fatalf("expected invalid port to fail the batch")
+	}
+	if err := r.SetSettings(map[string]any{"http.port": 7000, "region": "ap", "mode": "broken"}); err == nil || !strings.Contains(err.Error(), "broken mode") {
+		t.Fatalf("expected the SetFunc error, got %v", err)
+	}
+	if port != 9000 || region != "us" || mode != "fast" {
+		t.Fatalf("failed batches changed running values: port=%d region=%q mode=%q", port, region, mode)
+	}
+	saved, _ := r.savedStates(r.store)
+	if saved["http.port"].value != "9000" || saved["region"].value != "us" || saved["mode"].value != "fast" {
+		t.Fatalf("failed batch left saved values changed: %v", saved)
+	}
+}
+
+func TestSettingsSave_Pairs(t *testing.T) {
+	r := NewRegistry()
+	port, region := 8080, "eu"
+	r.RegisterIntSetting("http.port", "HTTP port", &port)
+	r.RegisterStringSetting("region", "Region", &region)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	out := captureStdout(func() {
+		if err := (&SettingsSaveCommand{Setting: "http.port=9000", Value: "region=us", Reason: "move"}).Run(r); err != nil {
+			t.Errorf("save pairs failed: %v", err)
+		}
+	})
+	if port != 9000 || region != "us" || !strings.Contains(out, "Setting region saved to us") {
+		t.Fatalf("pairs not saved: port=%d region=%q\n%s", port, region, out)
+	}
+	captureStdout(func() {
+		if err := (&SettingsSaveCommand{Setting: "region=ap", Value: "http.port=fast"}).Run(r); err == nil {
+			t.Errorf("expected invalid pair to fail")
+		}
+		if err := (&SettingsSaveCommand{Setting: "region=ap", Value: "region=us"}).Run(r); err == nil {
+			t.Errorf("expected duplicate setting to fail")
+		}
+		if err := (&SettingsSaveCommand{Setting: "region=ap", Value: "port"}).Run(r); err == nil {
+			t.Errorf("expected malformed pair to fail")
+		}
+	})
+	if region != "us" {
+		t.Fatalf("failed save changed region to %q", region)
+	}
+
+	var cli struct {
+		Settings SettingsCommand `cmd:""`
+	}
+	cli.Settings.Registry = r
+	parser, err := kong.New(&cli, kong.Exit(func(int) {}))
+	if err != nil {
+		t.Fatalf("kong.New failed: %v", err)
+	}
+	if _, err := parser.Parse([]string{"settings", "save", "region"}); err == nil {
+		t.Fatalf("expected save without a value to be rejected")
+	}
+	ctx, err := parser.Parse([]string{"settings", "save", "region", ""})
+	if err != nil {
+		t.Fatalf("save of an empty value failed to parse: %v", err)
+	}
+	captureStdout(func() {
+		if err := ctx.Run(); err != nil {
+			t.Errorf("save of an empty value failed: %v", err)
+		}
+	})
+	if region != "" {
+		t.Fatalf("expected an explicit empty value to be saved, got %q", region)
+	}
+}
+
+func TestSetSetting_UndoChecksVersionAndRecordsRollback(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var mode string
+	gormDB, err := gorm.Open(sqlite.Open(tempDBPath(t)), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db failed: %v", err)
+	}
+	r.RegisterSetting(&Setting{
+		Name:    "mode",
+		GetFunc: func() string { return mode },
+		SetFunc: func(s string) error {
+			switch s {
+			case "broken":
+				return errors.New("cannot switch to broken mode")
+			case "raced":
+				// Another writer saves a value after the failed write committed and before it is undone.
+				gormDB.Exec("UPDATE app_settings SET value = 'other', version = 999 WHERE key = 'mode'")
+				return errors.New("cannot switch to raced mode")
+			}
+			mode = s
+			return nil
+		},
+	})
+	if err := r.SetupWithDB(gormDB, SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	// Undoing a first save removes the row and is recorded as a rollback.
+	if err := r.SetSetting("mode", "broken"); err == nil {
+		t.Fatalf("expected the SetFunc error")
+	}
+	entries, _ := r.History("mode", time.Time{})
+	if len(entries) != 2 || entries[1].Operation != OpRollback || stateAfter(entries[1]).saved {
+		t.Fatalf("expected the undo to remove the row as a rollback, got %d entries", len(entries))
+	}
+	if saved, _ := r.savedStates(r.store); saved["mode"].saved {
+		t.Fatalf("undo left a saved value: %v", saved)
+	}
+
+	if err := r.SetSetting("mode", "fast"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	err = r.SetSetting("mode", "raced")
+	if err == nil || !strings.Contains(err.Error(), "restoring the previous saved values failed: setting mode was changed concurrently") {
+		t.Fatalf("expected the undo to report the concurrent change, got %v", err)
+	}
+	if saved, _ := r.savedStates(r.store); saved["mode"].value != "other" {
+		t.Fatalf("undo overwrote the concurrent change: %v", saved)
+	}
+}
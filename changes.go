@@ -0,0 +1,166 @@
+package app_settings
+
+import (
+	"context"
+	"errors"
+)
+
+// Change describes a setting value that has moved from Old to New.
+type Change struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// watchBufferSize is the number of undelivered changes a Watch channel holds before the oldest is dropped.
+const watchBufferSize = 16
+
+type subscription struct {
+	id uint64
+	fn func(context.Context, Change)
+	ch chan Change
+}
+
+// OnChange registers fn on the default registry. See Registry.OnChange.
+func OnChange(name string, fn func(old, new string)) (unsubscribe func()) {
+	return defaultRegistry.OnChange(name, fn)
+}
+
+// OnChangeContext registers fn on the default registry. See Registry.OnChangeContext.
+func OnChangeContext(name string, fn func(ctx context.Context, old, new string)) (unsubscribe func()) {
+	return defaultRegistry.OnChangeContext(name, fn)
+}
+
+// Watch subscribes to changes on the default registry. See Registry.Watch.
+func Watch(name string) (<-chan Change, func()) {
+	return defaultRegistry.Watch(name)
+}
+
+// OnChange calls fn after every successful change of the named setting, whether it was made from code,
+// the settings CLI or a reload from the database. Callbacks run synchronously on the goroutine that made
+// the change; a callback that panics or outlasts SettingsOptions.HookTimeout is reported to
+// SettingsOptions.OnError. The returned function removes the callback.
+func (r *Registry) OnChange(name string, fn func(old, new string)) (unsubscribe func()) {
+	return r.subscribe(name, &subscription{fn: func(_ context.Context, c Change) { fn(c.Old, c.New) }})
+}
+
+// OnChangeContext is OnChange for callbacks that take the context of the change: the one passed to
+// SetSettingContext and the other ...Context functions, or context.Background for changes made without one.
+// The callback is abandoned, and reported as failed, once that context is done.
+func (r *Registry) OnChangeContext(name string, fn func(ctx context.Context, old, new string)) (unsubscribe func()) {
+	return r.subscribe(name, &subscription{fn: func(ctx context.Context, c Change) { fn(ctx, c.Old, c.New) }})
+}
+
+// Watch returns a channel that receives every successful change of the named setting and a function that
+// stops the subscription and closes the channel. Deliveries never block the writer: when the channel's
+// buffer is full the oldest pending change is discarded in favour of the newest.
+func (r *Registry) Watch(name string) (<-chan Change, func()) {
+	ch := make(chan Change, watchBufferSize)
+	return ch, r.subscribe(name, &subscription{ch: ch})
+}
+
+func (r *Registry) subscribe(name string, sub *subscription) func() {
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+	if r.subs == nil {
+		r.subs = map[string][]*subscription{}
+	}
+	r.nextSubID++
+	sub.id = r.nextSubID
+	r.subs[name] = append(r.subs[name], sub)
+	return func() {
+		r.subMu.Lock()
+		defer r.subMu.Unlock()
+		subs := r.subs[name]
+		for i, s := range subs {
+			if s.id == sub.id {
+				r.subs[name] = append(subs[:i:i], subs[i+1:]...)
+				if s.ch != nil {
+					close(s.ch)
+				}
+				return
+			}
+		}
+	}
+}
+
+// notify delivers c to the subscribers of c.Name, passing ctx to the callbacks.
+func (r *Registry) notify(ctx context.Context, c Change) {
+	r.subMu.Lock()
+	subs := append([]*subscription(nil), r.subs[c.Name]...)
+	for _, s := range subs {
+		if s.ch == nil {
+			continue
+		}
+		select {
+		case s.ch <- c:
+		default:
+			select {
+			case <-s.ch:
+			default:
+			}
+			s.ch <- c
+		}
+	}
+	r.subMu.Unlock()
+	for _, s := range subs {
+		if s.fn == nil {
+			continue
+		}
+		err := r.runHook(ctx, c.Name, "change callback", func() (err error) {
+			defer recoverHook(&err, c.Name, "change callback")
+			s.fn(ctx, c)
+			return nil
+		})
+		if err != nil {
+			r.reportError(err)
+		}
+	}
+}
+
+// apply validates the value, runs the setting's SetFunc, records source as the origin of the new value and
+// notifies subscribers when the running value changed.
+func (r *Registry) apply(ctx context.Context, setting *Setting, value string, source Source) error {
+	c, err := r.set(ctx, setting, value, source)
+	if err != nil {
+		return err
+	}
+	if c.Old != c.New {
+		r.notify(ctx, c)
+	}
+	return nil
+}
+
+// set is apply without the notification, for callers that must notify only once a batch of changes has
+// been committed. The returned Change holds the running value before and after.
+func (r *Registry) set(ctx context.Context, setting *Setting, value string, source Source) (Change, error) {
+	if err := setting.Validate(value); err != nil {
+		return Change{}, setting.maskError(err)
+	}
+	// The running value is read while the SetFunc slot is held, so it never races a SetFunc given up on.
+	var old, updated string
+	err := r.runSetFunc(ctx, setting, func() error {
+		old = setting.GetFunc()
+		if err := setting.Apply(value); err != nil {
+			return err
+		}
+		updated = setting.GetFunc()
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrHookTimeout) || hookAbandoned(err) {
+			// These errors never hold the value.
+			return Change{}, err
+		}
+		return Change{}, setting.maskError(err)
+	}
+	r.recordSource(setting.Name, source, updated)
+	return Change{Name: setting.Name, Old: old, New: updated}, nil
+}
+
+// revert undoes a change made by set, restoring the previous running value and the source it came from. It
+// runs even when ctx is already cancelled.
+func (r *Registry) revert(ctx context.Context, setting *Setting, c Change, source Source) {
+	_ = r.runSetFunc(context.WithoutCancel(ctx), setting, func() error { return setting.Apply(c.Old) })
+	r.recordSource(setting.Name, source, c.Old)
+}
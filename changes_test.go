@@ -0,0 +1,91 @@
+package app_settings
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestOnChangeAndWatch_FireForEveryWritePath(t *testing.T) {
+	r := NewRegistry()
+	foo := "default"
+	r.RegisterStringSetting("foo", "Foo setting", &foo)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	var got []Change
+	unsubscribe := r.OnChange("foo", func(old, new string) {
+		got = append(got, Change{Name: "foo", Old: old, New: new})
+	})
+	ch, stop := r.Watch("foo")
+
+	if err := r.SetSetting("foo", "code"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	captureStdout(func() {
+		if err := (&SettingsSaveCommand{Setting: "foo", Value: "cli"}).Run(r); err != nil {
+			t.Errorf("save command failed: %v", err)
+		}
+	})
+	foo = "drifted"
+	if err := r.RetrieveAppSettings(); err != nil {
+		t.Fatalf("RetrieveAppSettings failed: %v", err)
+	}
+	// Setting the same value again is not a change.
+	if err := r.SetSetting("foo", "cli"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+
+	want := []Change{
+		{Name: "foo", Old: "default", New: "code"},
+		{Name: "foo", Old: "code", New: "cli"},
+		{Name: "foo", Old: "drifted", New: "cli"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d callbacks, got %#v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("callback %d: expected %#v, got %#v", i, want[i], got[i])
+		}
+		if c := <-ch; c != want[i] {
+			t.Fatalf("watch %d: expected %#v, got %#v", i, want[i], c)
+		}
+	}
+
+	unsubscribe()
+	stop()
+	if _, open := <-ch; open {
+		t.Fatalf("expected watch channel to be closed after stop")
+	}
+	if err := r.SetSetting("foo", "after"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("callback fired after unsubscribe: %#v", got)
+	}
+}
+
+func TestWatch_KeepsNewestWhenBufferIsFull(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var n int
+	r.RegisterIntSetting("n", "Counter", &n)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	ch, stop := r.Watch("n")
+	defer stop()
+	for i := 1; i <= watchBufferSize+5; i++ {
+		if err := r.SetSetting("n", i); err != nil {
+			t.Fatalf("SetSetting failed: %v", err)
+		}
+	}
+	var last Change
+	for len(ch) > 0 {
+		last = <-ch
+	}
+	if last.New != strconv.Itoa(watchBufferSize+5) {
+		t.Fatalf("expected newest change to be kept, got %#v", last)
+	}
+}
@@ -0,0 +1,177 @@
+package app_settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Codec converts a typed setting value to and from the string form stored in the settings table.
+type Codec[T any] interface {
+	Parse(string) (T, error)
+	Format(T) string
+}
+
+type funcCodec[T any] struct {
+	parse  func(string) (T, error)
+	format func(T) string
+}
+
+func (c funcCodec[T]) Parse(s string) (T, error) { return c.parse(s) }
+func (c funcCodec[T]) Format(v T) string         { return c.format(v) }
+
+// NewCodec builds a Codec from a parse and a format function.
+func NewCodec[T any](parse func(string) (T, error), format func(T) string) Codec[T] {
+	return funcCodec[T]{parse: parse, format: format}
+}
+
+// Codecs for every type the Register*Setting helpers support.
+var (
+	StringCodec = NewCodec(
+		func(s string) (string, error) { return s, nil },
+		func(v string) string { return v },
+	)
+	BoolCodec    = NewCodec(strconv.ParseBool, strconv.FormatBool)
+	IntCodec     = NewCodec(strconv.Atoi, strconv.Itoa)
+	Int8Codec    = intCodec[int8](8)
+	Int16Codec   = intCodec[int16](16)
+	Int32Codec   = intCodec[int32](32)
+	Int64Codec   = intCodec[int64](64)
+	UintCodec    = uintCodec[uint](64)
+	Uint8Codec   = uintCodec[uint8](8)
+	Uint16Codec  = uintCodec[uint16](16)
+	Uint32Codec  = uintCodec[uint32](32)
+	Uint64Codec  = uintCodec[uint64](64)
+	Float32Codec = NewCodec(
+		func(s string) (float32, error) {
+			f, err := strconv.ParseFloat(s, 32)
+			return float32(f), err
+		},
+		func(v float32) string { return strconv.FormatFloat(float64(v), 'f', -1, 32) },
+	)
+	Float64Codec = NewCodec(
+		func(s string) (float64, error) { return strconv.ParseFloat(s, 64) },
+		func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) },
+	)
+	DurationCodec = NewCodec(time.ParseDuration, time.Duration.String)
+	// TimeCodec uses RFC3339.
+	TimeCodec = NewCodec(
+		func(s string) (time.Time, error) { return time.Parse(time.RFC3339, s) },
+		func(v time.Time) string { return v.Format(time.RFC3339) },
+	)
+	// StringSliceCodec stores slices comma-separated; the empty string is a nil slice.
+	StringSliceCodec = NewCodec(
+		func(s string) ([]string, error) {
+			if s == "" {
+				return nil, nil
+			}
+			return strings.Split(s, ","), nil
+		},
+		func(v []string) string { return strings.Join(v, ",") },
+	)
+	IPCodec = NewCodec(
+		func(s string) (net.IP, error) {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP: %q", s)
+			}
+			return ip, nil
+		},
+		net.IP.String,
+	)
+	// IPNetCodec uses CIDR notation.
+	IPNetCodec = NewCodec(
+		func(s string) (net.IPNet, error) {
+			_, ipnet, err := net.ParseCIDR(s)
+			if err != nil {
+				return net.IPNet{}, err
+			}
+			return *ipnet, nil
+		},
+		func(v net.IPNet) string { return v.String() },
+	)
+	URLCodec = NewCodec(
+		func(s string) (url.URL, error) {
+			u, err := url.Parse(s)
+			if err != nil {
+				return url.URL{}, err
+			}
+			return *u, nil
+		},
+		func(v url.URL) string { return v.String() },
+	)
+	// CronCodec accepts cron expressions with an optional seconds field and descriptors such as @daily.
+	CronCodec = NewCodec(
+		func(s string) (string, error) {
+			parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+			if _, err := parser.Parse(s); err != nil {
+				return "", fmt.Errorf("invalid cron expression: %w", err)
+			}
+			return s, nil
+		},
+		func(v string) string { return v },
+	)
+)
+
+// JSONCodec stores values of T as JSON text.
+func JSONCodec[T any]() Codec[T] {
+	return NewCodec(
+		func(s string) (T, error) {
+			var value T
+			err := json.Unmarshal([]byte(s), &value)
+			return value, err
+		},
+		func(v T) string {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return ""
+			}
+			return string(b)
+		},
+	)
+}
+
+func intCodec[T int8 | int16 | int32 | int64](bits int) Codec[T] {
+	return NewCodec(
+		func(s string) (T, error) {
+			i, err := strconv.ParseInt(s, 10, bits)
+			return T(i), err
+		},
+		func(v T) string { return strconv.FormatInt(int64(v), 10) },
+	)
+}
+
+func uintCodec[T uint | uint8 | uint16 | uint32 | uint64](bits int) Codec[T] {
+	return NewCodec(
+		func(s string) (T, error) {
+			u, err := strconv.ParseUint(s, 10, bits)
+			return T(u), err
+		},
+		func(v T) string { return strconv.FormatUint(uint64(v), 10) },
+	)
+}
+
+// codecValueToString formats values of T with the codec and anything else with fmt, so SetSetting accepts
+// both typed values and their string form.
+func codecValueToString[T any](codec Codec[T]) func(any) (string, error) {
+	return func(value any) (string, error) {
+		if v, ok := value.(T); ok {
+			return codec.Format(v), nil
+		}
+		return fmt.Sprintf("%v", value), nil
+	}
+}
+
+// codecParseFunc checks that a string converts to T with the codec, for Setting.ParseFunc.
+func codecParseFunc[T any](codec Codec[T]) func(string) error {
+	return func(s string) error {
+		_, err := codec.Parse(s)
+		return err
+	}
+}
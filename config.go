@@ -0,0 +1,241 @@
+package app_settings
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// configFileFormat returns the format of a config file from its name: "json", "dotenv" or "properties".
+func configFileFormat(name string) (string, error) {
+	base := filepath.Base(name)
+	switch ext := filepath.Ext(base); {
+	case ext == ".json":
+		return "json", nil
+	case ext == ".env" || strings.HasPrefix(base, ".env"):
+		return "dotenv", nil
+	case ext == ".properties":
+		return "properties", nil
+	}
+	return "", fmt.Errorf("config file %s: unknown format; use a .json, .env or .properties file", name)
+}
+
+// readConfigFile reads a config file keyed by setting name.
+func readConfigFile(name string) (map[string]string, error) {
+	format, err := configFileFormat(name)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "json":
+		return readJSONConfig(name)
+	case "dotenv":
+		return readDotenv(name)
+	default:
+		return readProperties(name)
+	}
+}
+
+// readJSONConfig reads a JSON object of setting names to values. String values are used as they are; any
+// other value, such as a number or the document of a JSON setting, is used as its JSON text.
+func readJSONConfig(name string) (map[string]string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", name, err)
+	}
+	values := make(map[string]string, len(raw))
+	for key, msg := range raw {
+		var s string
+		if err := json.Unmarshal(msg, &s); err == nil {
+			values[key] = s
+			continue
+		}
+		values[key] = string(bytes.TrimSpace(msg))
+	}
+	return values, nil
+}
+
+// readProperties reads a Java properties file: "key=value", "key: value" or "key value" lines, # and !
+// comments, and values continued onto the next line with a trailing backslash.
+func readProperties(name string) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	logical := ""
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if logical == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+		if strings.HasSuffix(line, `\`) && !strings.HasSuffix(line, `\\`) {
+			logical += strings.TrimSuffix(line, `\`)
+			continue
+		}
+		logical += line
+		key, value := splitProperty(logical)
+		values[key] = value
+		logical = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	if logical != "" {
+		key, value := splitProperty(logical)
+		values[key] = value
+	}
+	return values, nil
+}
+
+var propertyEscapes = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t", `\r`, "\r", `\=`, "=", `\:`, ":", `\ `, " ", `\#`, "#", `\!`, "!")
+
+// splitProperty splits a logical properties line at the first unescaped '=', ':' or whitespace.
+func splitProperty(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t':
+			value := strings.TrimLeft(line[i+1:], " \t")
+			if line[i] == ' ' || line[i] == '\t' {
+				if len(value) > 0 && (value[0] == '=' || value[0] == ':') {
+					value = strings.TrimLeft(value[1:], " \t")
+				}
+			}
+			return propertyEscapes.Replace(line[:i]), propertyEscapes.Replace(value)
+		}
+	}
+	return propertyEscapes.Replace(line), ""
+}
+
+// configFileValues reads SettingsOptions.ConfigFiles, later files overriding earlier ones, and remembers the
+// result so a reset can fall back to it. Keys that match no registered setting are reported as errors.
+func (r *Registry) configFileValues() (map[string]layerValue, []error) {
+	r.mu.RLock()
+	files := r.configFiles
+	r.mu.RUnlock()
+	values := map[string]layerValue{}
+	errs := []error{}
+	for _, name := range files {
+		entries, err := readConfigFile(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for key, value := range entries {
+			if _, err := r.GetSetting(key); err != nil {
+				errs = append(errs, fmt.Errorf("config file %s: unknown setting %s", name, key))
+				continue
+			}
+			values[key] = layerValue{value: value, source: SourceFile, origin: name}
+		}
+	}
+	r.mu.Lock()
+	r.fileValues = values
+	r.mu.Unlock()
+	fileValues := make(map[string]layerValue, len(values))
+	for k, v := range values {
+		fileValues[k] = v
+	}
+	return fileValues, errs
+}
+
+// fileValue returns the value the config files provide for the named setting, if any.
+func (r *Registry) fileValue(name string) (layerValue, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.fileValues[name]
+	return v, ok
+}
+
+// fileStamp identifies a version of a config file by its modification time and size.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func configFileStamps(files []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, name := range files {
+		if info, err := os.Stat(name); err == nil {
+			stamps[name] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return stamps
+}
+
+// watchConfigFiles polls the config files every interval and reloads the settings when one of them no longer
+// matches stamps, until stop is closed.
+func (r *Registry) watchConfigFiles(files []string, stamps map[string]fileStamp, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		current := configFileStamps(files)
+		changed := len(current) != len(stamps)
+		for name, stamp := range current {
+			if previous, ok := stamps[name]; !ok || !previous.modTime.Equal(stamp.modTime) || previous.size != stamp.size {
+				changed = true
+			}
+		}
+		stamps = current
+		if !changed {
+			continue
+		}
+		if err := r.RetrieveAppSettings(); err != nil {
+			r.reportError(fmt.Errorf("reload after config file change: %w", err))
+		}
+	}
+}
+
+// reportError hands an error from a background task to SettingsOptions.OnError, or logs it.
+func (r *Registry) reportError(err error) {
+	r.mu.RLock()
+	onError := r.onError
+	r.mu.RUnlock()
+	if onError != nil {
+		onError(err)
+		return
+	}
+	log.Printf("app_settings: %v", err)
+}
+
+// Close stops the background tasks of the default registry. See Registry.Close.
+func Close() error {
+	return defaultRegistry.Close()
+}
+
+// Close stops the background tasks started by Setup, such as the config file watcher, and the RPC server
+// started by ServeRPC, dropping open connections. It is safe to call more than once.
+func (r *Registry) Close() error {
+	r.stopTasks()
+	return r.stopRPC(nil)
+}
+
+// stopTasks ends the background tasks started by setup.
+func (r *Registry) stopTasks() {
+	r.mu.Lock()
+	stop := r.stop
+	r.stop = nil
+	r.mu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+}
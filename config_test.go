@@ -0,0 +1,125 @@
+package app_settings
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s failed: %v", name, err)
+	}
+	return name
+}
+
+func TestConfigFiles_LayerBetweenDefaultsAndDB(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	jsonFile := writeFile(t, filepath.Join(dir, "base.json"), `{"http.port": 8000, "region": "eu", "hosts": "a,b"}`)
+	propsFile := writeFile(t, filepath.Join(dir, "site.properties"), "# site overrides\nregion = us\\\n-east\nbanner: hello\\: world\n")
+
+	port, region, banner := 80, "", ""
+	var hosts []string
+	r := NewRegistry()
+	r.RegisterIntSetting("http.port", "HTTP port", &port)
+	r.RegisterStringSetting("region", "Region", &region)
+	r.RegisterStringSetting("banner", "Banner", &banner)
+	r.RegisterStringSliceSetting("hosts", "Hosts", &hosts)
+	path := tempDBPath(t)
+	options := SettingsOptions{ConfigFiles: []string{jsonFile, propsFile}}
+	if err := r.Setup(path, options); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if port != 8000 || region != "us-east" || banner != "hello: world" || strings.Join(hosts, "|") != "a|b" {
+		t.Fatalf("config files not applied: port=%d region=%q banner=%q hosts=%v", port, region, banner, hosts)
+	}
+	if source, _ := r.ValueSource("region"); source != SourceFile {
+		t.Fatalf("expected file source, got %q", source)
+	}
+	if d, _ := r.defaultValue("http.port"); d != "80" {
+		t.Fatalf("config files must not change the recorded default, got %q", d)
+	}
+
+	if err := r.SetSetting("http.port", 9000); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if err := r.RetrieveAppSettings(); err != nil {
+		t.Fatalf("RetrieveAppSettings failed: %v", err)
+	}
+	if port != 9000 {
+		t.Fatalf("expected the saved value to win over the file, got %d", port)
+	}
+	if err := r.ResetSetting("http.port"); err != nil {
+		t.Fatalf("ResetSetting failed: %v", err)
+	}
+	if port != 8000 {
+		t.Fatalf("expected reset to return to the file value, got %d", port)
+	}
+}
+
+func TestConfigFiles_RejectUnknownKeysAndFormats(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	var region string
+	r := NewRegistry()
+	r.RegisterStringSetting("region", "Region", &region)
+	unknown := writeFile(t, filepath.Join(dir, "app.env"), "region=eu\nregoin=us\n")
+	err := r.Setup(tempDBPath(t), SettingsOptions{ConfigFiles: []string{unknown}})
+	if err == nil || !strings.Contains(err.Error(), "unknown setting regoin") {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+	if region != "eu" {
+		t.Fatalf("known keys should still apply, got %q", region)
+	}
+	yaml := writeFile(t, filepath.Join(dir, "app.yaml"), "region: eu\n")
+	if err := r.Setup(tempDBPath(t), SettingsOptions{ConfigFiles: []string{yaml}}); err == nil {
+		t.Fatalf("expected unknown format error")
+	}
+}
+
+func TestConfigFiles_WatcherReappliesChanges(t *testing.T) {
+	t.Parallel()
+	file := writeFile(t, filepath.Join(t.TempDir(), "app.json"), `{"region": "eu"}`)
+	var region string
+	r := NewRegistry()
+	r.RegisterStringSetting("region", "Region", &region)
+	changes, stop := r.Watch("region")
+	defer stop()
+	errs := make(chan error, 1)
+	options := SettingsOptions{
+		ConfigFiles:         []string{file},
+		ConfigWatchInterval: 10 * time.Millisecond,
+		OnError:             func(err error) { errs <- err },
+	}
+	if err := r.Setup(tempDBPath(t), options); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	defer r.Close()
+	<-changes
+
+	writeFile(t, file, `{"region": "us-west"}`)
+	select {
+	case c := <-changes:
+		if c.New != "us-west" {
+			t.Fatalf("unexpected change %#v", c)
+		}
+	case err := <-errs:
+		t.Fatalf("watcher reported error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watcher did not reapply the changed file")
+	}
+
+	writeFile(t, file, `{"region": "us-west", "bogus": 1}`)
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "bogus") {
+			t.Fatalf("unexpected watcher error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watcher did not report the unknown key")
+	}
+}
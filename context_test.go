@@ -0,0 +1,156 @@
+package app_settings
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetupContext_Cancelled(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var region string
+	r.RegisterStringSetting("region", "Region", &region)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.SetupContext(ctx, tempDBPath(t), SettingsOptions{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled setup to fail with context.Canceled, got %v", err)
+	}
+}
+
+func TestSetSettingContext_Cancelled(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	region := "eu"
+	r.RegisterStringSetting("region", "Region", &region)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.SetSettingContext(ctx, "region", "us"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if err := r.RetrieveAppSettingsContext(ctx); err == nil {
+		t.Fatalf("expected a cancelled load to fail")
+	}
+	if saved, _ := r.savedStates(r.store); region != "eu" || saved["region"].saved {
+		t.Fatalf("cancelled write changed the setting: running=%q saved=%v", region, saved["region"])
+	}
+}
+
+func TestSetSettingContext_DeadlineKeepsSavedValue(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var mu sync.Mutex
+	mode := "fast"
+	release := make(chan struct{})
+	r.RegisterSetting(&Setting{
+		Name: "mode",
+		GetFunc: func() string {
+			mu.Lock()
+			defer mu.Unlock()
+			return mode
+		},
+		SetFunc: func(s string) error {
+			if s == "stuck" {
+				<-release
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			mode = s
+			return nil
+		},
+	})
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.SetSetting("mode", "slow"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.SetSettingContext(ctx, "mode", "stuck"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	// The abandoned SetFunc may still apply the value, so it stays saved and is not reverted.
+	if saved, _ := r.savedStates(r.store); saved["mode"].value != "stuck" {
+		t.Fatalf("saved value changed after the deadline: %q", saved["mode"].value)
+	}
+	close(release)
+	waitFor(t, "the abandoned SetFunc to finish", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return mode == "stuck"
+	})
+}
+
+func TestOnChangeContext_ReceivesContext(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	region := "eu"
+	r.RegisterStringSetting("region", "Region", &region)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	type requestKey struct{}
+	var got any
+	r.OnChangeContext("region", func(ctx context.Context, _, _ string) { got = ctx.Value(requestKey{}) })
+	ctx := context.WithValue(context.Background(), requestKey{}, "req-42")
+	if err := r.SetSettingContext(ctx, "region", "us"); err != nil {
+		t.Fatalf("SetSettingContext failed: %v", err)
+	}
+	if got != "req-42" {
+		t.Fatalf("callback did not receive the context of the change: %v", got)
+	}
+}
+
+func TestContextVariants_Cancelled(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var region string
+	r.RegisterStringSetting("region", "Region", &region)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{Encryption: &EncryptionOptions{Key: StaticKey(make([]byte, 32))}}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	for _, v := range []string{"eu", "us"} {
+		if err := r.SetSetting("region", v); err != nil {
+			t.Fatalf("SetSetting failed: %v", err)
+		}
+	}
+	file := writeFile(t, filepath.Join(t.TempDir(), "settings.json"), `{"region": "ap"}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := map[string]func() error{
+		"RollbackContext":          func() error { return r.RollbackContext(ctx, "region") },
+		"RollbackToVersionContext": func() error { return r.RollbackToVersionContext(ctx, "region", 1) },
+		"RollbackToTimeContext":    func() error { return r.RollbackToTimeContext(ctx, "region", time.Now()) },
+		"RollbackAllContext":       func() error { return r.RollbackAllContext(ctx, time.Now()) },
+		"ExportContext":            func() error { return r.ExportContext(ctx, io.Discard, ExportOptions{}) },
+		"ImportContext": func() error {
+			_, err := r.ImportContext(ctx, file, ImportOptions{})
+			return err
+		},
+		"HistoryContext": func() error {
+			_, err := r.HistoryContext(ctx, "region", time.Time{})
+			return err
+		},
+		"SavedVersionContext": func() error {
+			_, err := r.SavedVersionContext(ctx, "region")
+			return err
+		},
+		"RekeyContext": func() error { return r.RekeyContext(ctx, StaticKey(make([]byte, 16))) },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, context.Canceled) {
+			t.Fatalf("%s: expected context.Canceled, got %v", name, err)
+		}
+	}
+	if saved, _ := r.savedStates(r.store); region != "us" || saved["region"].value != "us" {
+		t.Fatalf("cancelled calls changed the setting: running=%q saved=%v", region, saved["region"])
+	}
+}
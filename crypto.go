@@ -0,0 +1,438 @@
+package app_settings
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dan-sherwin/go-app-settings/db"
+	"github.com/dan-sherwin/go-app-settings/db/models"
+	"gorm.io/gorm/clause"
+)
+
+// encryptedPrefix marks a stored value as AES-GCM ciphertext: "enc:v1:" + base64(nonce || ciphertext).
+const encryptedPrefix = "enc:v1:"
+
+type (
+	// EncryptionOptions enables encryption at rest of setting values.
+	EncryptionOptions struct {
+		// Key supplies the AES key (16, 24 or 32 bytes).
+		Key KeyProvider
+		// All encrypts every setting; otherwise only settings marked Encrypted are encrypted.
+		All bool
+	}
+
+	// KeyProvider supplies the AES key used to encrypt setting values.
+	KeyProvider interface {
+		Key() ([]byte, error)
+	}
+
+	// KeyFile reads the key from a file holding the key encoded as hex or base64, read exactly like EnvKey,
+	// or else the raw key bytes.
+	KeyFile string
+
+	// EnvKey reads a hex or base64 encoded key from the named environment variable.
+	EnvKey string
+
+	// StaticKey is a key held in memory, for tests and custom key management.
+	StaticKey []byte
+)
+
+func (f KeyFile) Key() ([]byte, error) {
+	data, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	key, err := decodeKey(strings.TrimSpace(string(data)))
+	if err != nil && validKeyLength(len(data)) {
+		// Encoded text takes precedence so a file and an environment variable holding the same text
+		// yield the same key.
+		return data, nil
+	}
+	return key, err
+}
+
+func (e EnvKey) Key() ([]byte, error) {
+	value, ok := os.LookupEnv(string(e))
+	if !ok || value == "" {
+		return nil, fmt.Errorf("environment variable %s is not set", string(e))
+	}
+	return decodeKey(strings.TrimSpace(value))
+}
+
+func (k StaticKey) Key() ([]byte, error) {
+	if !validKeyLength(len(k)) {
+		return nil, fmt.Errorf("invalid key length %d: must be 16, 24 or 32 bytes", len(k))
+	}
+	return k, nil
+}
+
+func decodeKey(text string) ([]byte, error) {
+	for _, decode := range []func(string) ([]byte, error){hex.DecodeString, base64.StdEncoding.DecodeString} {
+		if key, err := decode(text); err == nil && validKeyLength(len(key)) {
+			return key, nil
+		}
+	}
+	return nil, errors.New("key must be 16, 24 or 32 bytes, raw or encoded as hex or base64")
+}
+
+func validKeyLength(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// Encrypted marks a setting to be stored encrypted when encryption is configured.
+func Encrypted() Option {
+	return func(s *Setting) {
+		s.Encrypted = true
+	}
+}
+
+// valueCipher encrypts and decrypts stored values. The setting name is authenticated with each value
+// so ciphertext cannot be moved between rows.
+type valueCipher struct {
+	aead cipher.AEAD
+	all  bool
+}
+
+func newValueCipher(key KeyProvider, all bool) (*valueCipher, error) {
+	if key == nil {
+		return nil, errors.New("encryption requires a key provider")
+	}
+	k, err := key.Key()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &valueCipher{aead: aead, all: all}, nil
+}
+
+func (c *valueCipher) encrypt(name, value string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *valueCipher) decrypt(name, stored string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decrypt setting %s: %w", name, err)
+	}
+	if len(data) < c.aead.NonceSize() {
+		return "", fmt.Errorf("decrypt setting %s: ciphertext too short", name)
+	}
+	nonce, sealed := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("decrypt setting %s: %w", name, err)
+	}
+	return string(plain), nil
+}
+
+func (c *valueCipher) shouldEncrypt(setting *Setting) bool {
+	return c != nil && (c.all || (setting != nil && setting.Encrypted))
+}
+
+// encodeValue returns the form of value stored in the database for the named setting.
+func encodeValue(c *valueCipher, setting *Setting, name, value string) (string, error) {
+	if !c.shouldEncrypt(setting) {
+		return value, nil
+	}
+	return c.encrypt(name, value)
+}
+
+// decodeValue reverses encodeValue. Values are decrypted by their prefix, so turning encryption on or off
+// for a setting does not strand rows written before the change.
+func decodeValue(c *valueCipher, name, stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return stored, nil
+	}
+	if c == nil {
+		return "", fmt.Errorf("setting %s is encrypted but no encryption key is configured", name)
+	}
+	return c.decrypt(name, stored)
+}
+
+// writeVersion saves value for setting inside tx, encrypting it when required, and records op in the history.
+// When ifVersion is not nil, it first checks that the saved value is still at that version and fails with a
+// *ConflictError otherwise; a row another writer created or changed after it was read is a *ConflictError as
+// well. The row takes the ID of its history entry as its new version, which writeVersion returns.
+func (r *Registry) writeVersion(tx *db.Store, setting *Setting, value, op, reason string, ifVersion *int64) (int64, error) {
+	r.mu.RLock()
+	c := r.cipher
+	r.mu.RUnlock()
+	stored, err := encodeValue(c, setting, setting.Name, value)
+	if err != nil {
+		return 0, err
+	}
+	a := tx.AppSetting
+	rows, err := a.Where(a.Key.Eq(setting.Name)).Limit(1).Find()
+	if err != nil {
+		return 0, err
+	}
+	exists := len(rows) > 0
+	old, current := "", int64(0)
+	if exists {
+		if old, err = historyValue(c, setting, setting.Name, rows[0].Value); err != nil {
+			return 0, err
+		}
+		current = rows[0].Version
+	}
+	if ifVersion != nil && *ifVersion != current {
+		return 0, &ConflictError{Setting: setting.Name, Expected: *ifVersion, Actual: current}
+	}
+	version, err := r.recordHistory(tx, setting.Name, op, old, stored, exists, true, reason)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		// Another writer may have created the row since it was read: the insert then does nothing and the
+		// row read back carries that writer's version.
+		if err := a.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.AppSetting{Key: setting.Name, Value: stored, Version: version}); err != nil {
+			return 0, err
+		}
+		rows, err := a.Where(a.Key.Eq(setting.Name)).Limit(1).Find()
+		if err != nil {
+			return 0, err
+		}
+		if len(rows) == 0 || rows[0].Version != version {
+			actual := int64(0)
+			if len(rows) > 0 {
+				actual = rows[0].Version
+			}
+			return 0, &ConflictError{Setting: setting.Name, Expected: current, Actual: actual}
+		}
+		return version, nil
+	}
+	// The version condition catches a writer that changed the row since it was read.
+	info, err := a.Where(a.Key.Eq(setting.Name), a.Version.Eq(current)).UpdateSimple(a.Value.Value(stored), a.Version.Value(version))
+	if err != nil {
+		return 0, err
+	}
+	if info.RowsAffected == 0 {
+		actual := int64(0)
+		if rows, err := a.Where(a.Key.Eq(setting.Name)).Limit(1).Find(); err == nil && len(rows) > 0 {
+			actual = rows[0].Version
+		}
+		return 0, &ConflictError{Setting: setting.Name, Expected: current, Actual: actual}
+	}
+	return version, nil
+}
+
+// removeValue deletes the saved value of setting inside tx and records op in the history. It reports whether
+// a value was saved. When ifVersion is not nil, the saved value must still be at that version, 0 for none, or
+// it fails with a *ConflictError.
+func (r *Registry) removeValue(tx *db.Store, setting *Setting, op, reason string, ifVersion *int64) (bool, error) {
+	r.mu.RLock()
+	c := r.cipher
+	r.mu.RUnlock()
+	a := tx.AppSetting
+	rows, err := a.Where(a.Key.Eq(setting.Name)).Limit(1).Find()
+	if err != nil {
+		return false, err
+	}
+	current := int64(0)
+	if len(rows) > 0 {
+		current = rows[0].Version
+	}
+	if ifVersion != nil && *ifVersion != current {
+		return false, &ConflictError{Setting: setting.Name, Expected: *ifVersion, Actual: current}
+	}
+	if len(rows) == 0 {
+		return false, nil
+	}
+	old, err := historyValue(c, setting, setting.Name, rows[0].Value)
+	if err != nil {
+		return false, err
+	}
+	// As in writeVersion, the version condition catches a writer that changed the row since it was read.
+	info, err := a.Where(a.Key.Eq(setting.Name), a.Version.Eq(current)).Delete()
+	if err != nil {
+		return false, err
+	}
+	if info.RowsAffected == 0 {
+		actual := int64(0)
+		if rows, err := a.Where(a.Key.Eq(setting.Name)).Limit(1).Find(); err == nil && len(rows) > 0 {
+			actual = rows[0].Version
+		}
+		return false, &ConflictError{Setting: setting.Name, Expected: current, Actual: actual}
+	}
+	_, err = r.recordHistory(tx, setting.Name, op, old, "", true, false, reason)
+	return true, err
+}
+
+// historyValue returns a value stored under name in the form the history table keeps it: encrypted when the
+// setting is encrypted now, so the history never holds a secret in clear text.
+func historyValue(c *valueCipher, setting *Setting, name, stored string) (string, error) {
+	if strings.HasPrefix(stored, encryptedPrefix) || !c.shouldEncrypt(setting) {
+		return stored, nil
+	}
+	return c.encrypt(name, stored)
+}
+
+// loadValues returns the saved rows with the values of registered settings decrypted. Rows that belong to
+// no registered setting are returned as stored.
+func (r *Registry) loadValues(store *db.Store) ([]*models.AppSetting, error) {
+	rows, err := store.AppSetting.Find()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	c := r.cipher
+	r.mu.RUnlock()
+	for _, row := range rows {
+		if _, err := r.GetSetting(row.Key); err != nil {
+			continue
+		}
+		if row.Value, err = decodeValue(c, row.Key, row.Value); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// Rekey re-encrypts every saved row of the default registry. See Registry.Rekey.
+func Rekey(newKey KeyProvider) error {
+	return defaultRegistry.Rekey(newKey)
+}
+
+// RekeyContext re-encrypts every saved row of the default registry. See Registry.RekeyContext.
+func RekeyContext(ctx context.Context, newKey KeyProvider) error {
+	return defaultRegistry.RekeyContext(ctx, newKey)
+}
+
+// Rekey decrypts every saved row, scoped override and history entry with the current key and re-encrypts it under newKey
+// inside a single transaction. On success the registry uses newKey from then on; on failure nothing is changed.
+func (r *Registry) Rekey(newKey KeyProvider) error {
+	return r.RekeyContext(context.Background(), newKey)
+}
+
+// RekeyContext is Rekey with a context bounding the transaction.
+func (r *Registry) RekeyContext(ctx context.Context, newKey KeyProvider) error {
+	store, err := r.storeContext(ctx)
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	current := r.cipher
+	r.mu.RUnlock()
+	if current == nil {
+		return errors.New("encryption is not configured")
+	}
+	next, err := newValueCipher(newKey, current.all)
+	if err != nil {
+		return err
+	}
+	err = store.Transaction(func(tx *db.Store) error {
+		rows, err := tx.AppSetting.Find()
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			value, err := decodeValue(current, row.Key, row.Value)
+			if err != nil {
+				return err
+			}
+			setting, _ := r.GetSetting(row.Key)
+			if !strings.HasPrefix(row.Value, encryptedPrefix) && !next.shouldEncrypt(setting) {
+				continue
+			}
+			if row.Value, err = next.encrypt(row.Key, value); err != nil {
+				return err
+			}
+			if err := tx.AppSetting.Save(row); err != nil {
+				return err
+			}
+		}
+		scoped, err := tx.AppSettingScoped.Find()
+		if err != nil {
+			return err
+		}
+		for _, row := range scoped {
+			name := scopedName(row.Key, row.Scope)
+			value, err := decodeValue(current, name, row.Value)
+			if err != nil {
+				return err
+			}
+			setting, _ := r.GetSetting(row.Key)
+			if !strings.HasPrefix(row.Value, encryptedPrefix) && !next.shouldEncrypt(setting) {
+				continue
+			}
+			if row.Value, err = next.encrypt(name, value); err != nil {
+				return err
+			}
+			if err := tx.AppSettingScoped.Save(row); err != nil {
+				return err
+			}
+		}
+		history, err := tx.AppSettingHistory.Find()
+		if err != nil {
+			return err
+		}
+		for _, h := range history {
+			changed := false
+			for _, v := range []*string{&h.OldValue, &h.NewValue} {
+				if !strings.HasPrefix(*v, encryptedPrefix) {
+					continue
+				}
+				plain, err := current.decrypt(h.Key, *v)
+				if err != nil {
+					return err
+				}
+				if *v, err = next.encrypt(h.Key, plain); err != nil {
+					return err
+				}
+				changed = true
+			}
+			if changed {
+				if err := tx.AppSettingHistory.Save(h); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("rekey failed, no rows changed: %w", err)
+	}
+	r.mu.Lock()
+	r.cipher = next
+	r.mu.Unlock()
+	return nil
+}
+
+// Run re-encrypts every saved setting under a new key.
+func (c *SettingsRekeyCommand) Run(r *Registry) error {
+	var key KeyProvider
+	switch {
+	case c.NewKeyFile != "" && c.NewKeyEnv != "":
+		return printAndReturnErr(errors.New("specify only one of --new-key-file and --new-key-env"))
+	case c.NewKeyFile != "":
+		key = KeyFile(c.NewKeyFile)
+	case c.NewKeyEnv != "":
+		key = EnvKey(c.NewKeyEnv)
+	default:
+		return printAndReturnErr(errors.New("specify --new-key-file or --new-key-env"))
+	}
+	if err := r.Rekey(key); err != nil {
+		return printAndReturnErr(err)
+	}
+	fmt.Println("Settings re-encrypted; update the application's key provider to the new key")
+	return nil
+}
@@ -0,0 +1,154 @@
+package app_settings
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func openEncryptedRegistry(t *testing.T, gormDB *gorm.DB, key KeyProvider) (*Registry, *string, *string) {
+	t.Helper()
+	r := NewRegistry()
+	password := "default-password"
+	region := "eu"
+	r.RegisterSecretSetting("db.password", "Database password", &password, Encrypted())
+	r.RegisterStringSetting("region", "Region", &region)
+	if err := r.SetupWithDB(gormDB, SettingsOptions{Encryption: &EncryptionOptions{Key: key}}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	return r, &password, &region
+}
+
+func rawValue(t *testing.T, gormDB *gorm.DB, key string) string {
+	t.Helper()
+	var value string
+	if err := gormDB.Table(DefaultTableName).Select("value").Where("key = ?", key).Scan(&value).Error; err != nil {
+		t.Fatalf("read raw value failed: %v", err)
+	}
+	return value
+}
+
+func TestEncryption_EncryptsChosenSettingsAndRekeys(t *testing.T) {
+	t.Parallel()
+	gormDB, err := gorm.Open(sqlite.Open(tempDBPath(t)), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db failed: %v", err)
+	}
+	oldKey := StaticKey(bytes.Repeat([]byte{1}, 32))
+	r, _, _ := openEncryptedRegistry(t, gormDB, oldKey)
+	if err := r.SetSetting("db.password", "hunter2"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if err := r.SetSetting("region", "us"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if raw := rawValue(t, gormDB, "db.password"); !strings.HasPrefix(raw, encryptedPrefix) || strings.Contains(raw, "hunter2") {
+		t.Fatalf("expected encrypted value at rest, got %q", raw)
+	}
+	if raw := rawValue(t, gormDB, "region"); raw != "us" {
+		t.Fatalf("expected unencrypted setting to be stored as is, got %q", raw)
+	}
+
+	_, password, region := openEncryptedRegistry(t, gormDB, oldKey)
+	if *password != "hunter2" || *region != "us" {
+		t.Fatalf("values not decrypted on retrieve: %q %q", *password, *region)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "settings.key")
+	newKey := bytes.Repeat([]byte{2}, 32)
+	if err := os.WriteFile(keyFile, []byte(hex.EncodeToString(newKey)+"\n"), 0o600); err != nil {
+		t.Fatalf("write key file failed: %v", err)
+	}
+	captureStdout(func() {
+		err = (&SettingsRekeyCommand{NewKeyFile: keyFile}).Run(r)
+	})
+	if err != nil {
+		t.Fatalf("rekey failed: %v", err)
+	}
+
+	if err := NewRegistry().SetupWithDB(gormDB, SettingsOptions{Encryption: &EncryptionOptions{Key: oldKey}}); err != nil {
+		t.Fatalf("setup without registered settings failed: %v", err)
+	}
+	stale := NewRegistry()
+	var stalePassword string
+	stale.RegisterStringSetting("db.password", "", &stalePassword)
+	if err := stale.SetupWithDB(gormDB, SettingsOptions{Encryption: &EncryptionOptions{Key: oldKey}}); err == nil {
+		t.Fatalf("expected the old key to be unable to decrypt after rekey")
+	}
+	_, password, _ = openEncryptedRegistry(t, gormDB, KeyFile(keyFile))
+	if *password != "hunter2" {
+		t.Fatalf("expected value readable with the new key, got %q", *password)
+	}
+}
+
+func TestEncryption_AllAndMissingKey(t *testing.T) {
+	t.Parallel()
+	gormDB, err := gorm.Open(sqlite.Open(tempDBPath(t)), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db failed: %v", err)
+	}
+	r := NewRegistry()
+	region := "eu"
+	r.RegisterStringSetting("region", "Region", &region)
+	if err := r.SetupWithDB(gormDB, SettingsOptions{Encryption: &EncryptionOptions{Key: StaticKey(bytes.Repeat([]byte{3}, 16)), All: true}}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.SetSetting("region", "us"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if raw := rawValue(t, gormDB, "region"); !strings.HasPrefix(raw, encryptedPrefix) {
+		t.Fatalf("expected All to encrypt every setting, got %q", raw)
+	}
+
+	plain := NewRegistry()
+	plain.RegisterStringSetting("region", "Region", &region)
+	if err := plain.SetupWithDB(gormDB, SettingsOptions{}); err == nil || !strings.Contains(err.Error(), "no encryption key") {
+		t.Fatalf("expected missing key error, got %v", err)
+	}
+	if _, err := (EnvKey("APP_SETTINGS_TEST_UNSET_KEY")).Key(); err == nil {
+		t.Fatalf("expected error for unset key variable")
+	}
+	if err := NewRegistry().SetupWithDB(gormDB, SettingsOptions{Encryption: &EncryptionOptions{Key: StaticKey("short")}}); err == nil {
+		t.Fatalf("expected invalid key length error")
+	}
+}
+
+func TestKeyFile_ReadsEncodedKeysLikeEnvKey(t *testing.T) {
+	dir := t.TempDir()
+	for _, text := range []string{
+		hex.EncodeToString(bytes.Repeat([]byte{4}, 16)),
+		"BQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUF", // base64 of 24 bytes
+	} {
+		path := filepath.Join(dir, "key")
+		if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+			t.Fatalf("write key file failed: %v", err)
+		}
+		t.Setenv("APP_SETTINGS_TEST_KEY", text)
+		fromFile, err := KeyFile(path).Key()
+		if err != nil {
+			t.Fatalf("KeyFile failed: %v", err)
+		}
+		fromEnv, err := EnvKey("APP_SETTINGS_TEST_KEY").Key()
+		if err != nil {
+			t.Fatalf("EnvKey failed: %v", err)
+		}
+		if !bytes.Equal(fromFile, fromEnv) {
+			t.Fatalf("KeyFile read %q as %d bytes, EnvKey as %d", text, len(fromFile), len(fromEnv))
+		}
+	}
+
+	raw := bytes.Repeat([]byte{0xff}, 32)
+	path := filepath.Join(dir, "raw.key")
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatalf("write key file failed: %v", err)
+	}
+	if key, err := KeyFile(path).Key(); err != nil || !bytes.Equal(key, raw) {
+		t.Fatalf("raw key file read as %x, %v", key, err)
+	}
+}
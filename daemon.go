@@ -0,0 +1,122 @@
+package app_settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SettingResult is the outcome of reloading one setting in the running application.
+type SettingResult struct {
+	Name string
+	// Value is the running value after the reload, masked for sensitive settings.
+	Value string
+	// Source is where the running value came from.
+	Source Source
+	// Error is the reason the value could not be applied, empty on success.
+	Error string
+}
+
+// ReloadSettings reloads settings of the default registry. See Registry.ReloadSettings.
+func ReloadSettings(names ...string) ([]SettingResult, error) {
+	return defaultRegistry.ReloadSettings(names...)
+}
+
+// ReloadSettingsContext reloads settings of the default registry. See Registry.ReloadSettingsContext.
+func ReloadSettingsContext(ctx context.Context, names ...string) ([]SettingResult, error) {
+	return defaultRegistry.ReloadSettingsContext(ctx, names...)
+}
+
+// ReloadSettings re-reads the saved values of the named settings and applies them through SetFunc, falling
+// back to the config file value or the default for settings without a saved value. Settings pinned by the
+// environment or the command line keep their value. The error is for failures reading the database; a value
+// a setting rejects is reported in its result.
+func (r *Registry) ReloadSettings(names ...string) ([]SettingResult, error) {
+	return r.ReloadSettingsContext(context.Background(), names...)
+}
+
+// ReloadSettingsContext is ReloadSettings with a context bounding the database query and the hooks.
+func (r *Registry) ReloadSettingsContext(ctx context.Context, names ...string) ([]SettingResult, error) {
+	store, err := r.storeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := r.savedStates(store)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.refreshScoped(store); err != nil {
+		return nil, err
+	}
+	results := make([]SettingResult, 0, len(names))
+	for _, name := range names {
+		setting, err := r.GetSetting(name)
+		if err != nil {
+			results = append(results, SettingResult{Name: name, Error: err.Error()})
+			continue
+		}
+		result := SettingResult{Name: name}
+		v := layerValue{value: saved[name].value, source: SourceDB}
+		ok := !r.pinned(name)
+		if !saved[name].saved {
+			defaultValue, _ := r.defaultValue(name)
+			v, ok = r.baseValue(setting, defaultValue)
+		}
+		if ok {
+			if err := r.apply(ctx, setting, v.value, v.source); err != nil {
+				result.Error = err.Error()
+			}
+		}
+		result.Value = setting.display(setting.GetFunc())
+		result.Source = r.source(setting)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// ReloadSettings reloads the named settings in the application serving the socket, for the settings CLI.
+func (s *settingsService) ReloadSettings(names *[]string, results *[]SettingResult) error {
+	reloaded, err := s.registry.ReloadSettings(*names...)
+	if err != nil {
+		return err
+	}
+	*results = reloaded
+	return nil
+}
+
+// applyToDaemon asks the application behind RpcSocketPathToListRunningSettings to reload the named settings
+// after the CLI changed their saved values, and prints the outcome for each. Without a configured socket it
+// does nothing; when no application is listening the change stays saved and a notice says so. It returns an
+// error when the application rejected a value. ctx bounds the connection and the call.
+func (r *Registry) applyToDaemon(ctx context.Context, names ...string) error {
+	r.mu.RLock()
+	socketPath := r.socketPath
+	r.mu.RUnlock()
+	if socketPath == "" || len(names) == 0 {
+		return nil
+	}
+	client, err := r.dialDaemon(ctx)
+	if err != nil {
+		fmt.Printf("Settings persisted, not applied: no running application is listening on %s\n", socketPath)
+		return nil
+	}
+	defer client.Close()
+	results := []SettingResult{}
+	if err := callDaemon(ctx, client, "ReloadSettings", &names, &results); err != nil {
+		fmt.Printf("Settings persisted, not applied: %v\n", err)
+		return nil
+	}
+	rejected := 0
+	for _, res := range results {
+		if res.Error != "" {
+			rejected++
+			fmt.Printf("Setting %s not applied by the running application: %s\n", res.Name, res.Error)
+			continue
+		}
+		fmt.Printf("Setting %s applied to the running application: %s (%s)\n", res.Name, res.Value, res.Source)
+	}
+	if rejected > 0 {
+		return errors.New("the running application rejected some settings; their saved values are not in effect")
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package app_settings
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDaemon_CLIChangesReachRunningApplication(t *testing.T) {
+	path, socket := tempDBPath(t), shortSocketPath(t)
+	options := SettingsOptions{RpcSocketPathToListRunningSettings: socket}
+	daemon := NewRegistry()
+	daemonRegion, daemonPort := "eu", 8080
+	daemon.RegisterStringSetting("region", "Region", &daemonRegion)
+	daemon.RegisterIntSetting("http.port", "HTTP port", &daemonPort, WithValidator("below-9000", func(v string) error {
+		if v >= "9000" {
+			return errors.New("port must be below 9000")
+		}
+		return nil
+	}))
+	if err := daemon.Setup(path, options); err != nil {
+		t.Fatalf("daemon setup failed: %v", err)
+	}
+
+	cli := NewRegistry()
+	cliRegion, cliPort := "eu", 8080
+	cli.RegisterStringSetting("region", "Region", &cliRegion)
+	cli.RegisterIntSetting("http.port", "HTTP port", &cliPort)
+	if err := cli.Setup(path, options); err != nil {
+		t.Fatalf("cli setup failed: %v", err)
+	}
+
+	out := captureStdout(func() {
+		if err := (&SettingsSaveCommand{Setting: "region", Value: "us"}).Run(cli); err != nil {
+			t.Errorf("save failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "persisted, not applied") || daemonRegion != "eu" {
+		t.Fatalf("expected a not-applied notice without a listening daemon: %s", out)
+	}
+
+	serveRegistry(t, daemon)
+	out = captureStdout(func() {
+		if err := (&SettingsSaveCommand{Setting: "region", Value: "ap"}).Run(cli); err != nil {
+			t.Errorf("save failed: %v", err)
+		}
+	})
+	if daemonRegion != "ap" || !strings.Contains(out, "applied to the running application: ap (db)") {
+		t.Fatalf("save not applied to the daemon (region=%q): %s", daemonRegion, out)
+	}
+
+	var err error
+	out = captureStdout(func() {
+		err = (&SettingsSaveCommand{Setting: "http.port", Value: "9100"}).Run(cli)
+	})
+	if err == nil || daemonPort != 8080 || !strings.Contains(out, "port must be below 9000") {
+		t.Fatalf("expected the daemon's validation error (err=%v, port=%d): %s", err, daemonPort, out)
+	}
+
+	captureStdout(func() {
+		if err := (&SettingsRemoveCommand{Setting: "region"}).Run(cli); err != nil {
+			t.Errorf("remove failed: %v", err)
+		}
+	})
+	if daemonRegion != "eu" {
+		t.Fatalf("remove not applied to the daemon, region=%q", daemonRegion)
+	}
+}
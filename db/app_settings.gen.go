@@ -24,6 +24,7 @@ func newAppSetting(db *gorm.DB, opts ...gen.DOOption) appSetting {
 	_appSetting.ALL = field.NewAsterisk(tableName)
 	_appSetting.Key = field.NewString(tableName, "key")
 	_appSetting.Value = field.NewString(tableName, "value")
+	_appSetting.Version = field.NewInt64(tableName, "version")
 
 	_appSetting.fillFieldMap()
 
@@ -33,9 +34,10 @@ func newAppSetting(db *gorm.DB, opts ...gen.DOOption) appSetting {
 type appSetting struct {
 	appSettingDo
 
-	ALL   field.Asterisk
-	Key   field.String
-	Value field.String
+	ALL     field.Asterisk
+	Key     field.String
+	Value   field.String
+	Version field.Int64
 
 	fieldMap map[string]field.Expr
 }
@@ -54,6 +56,7 @@ func (a *appSetting) updateTableName(table string) *appSetting {
 	a.ALL = field.NewAsterisk(table)
 	a.Key = field.NewString(table, "key")
 	a.Value = field.NewString(table, "value")
+	a.Version = field.NewInt64(table, "version")
 
 	a.fillFieldMap()
 
@@ -70,9 +73,10 @@ func (a *appSetting) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (a *appSetting) fillFieldMap() {
-	a.fieldMap = make(map[string]field.Expr, 2)
+	a.fieldMap = make(map[string]field.Expr, 3)
 	a.fieldMap["key"] = a.Key
 	a.fieldMap["value"] = a.Value
+	a.fieldMap["version"] = a.Version
 }
 
 func (a appSetting) clone(db *gorm.DB) appSetting {
@@ -0,0 +1,419 @@
+package db
+
+import (
+	"context"
+	"github.com/dan-sherwin/go-app-settings/db/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+)
+
+func newAppSettingHistory(db *gorm.DB, opts ...gen.DOOption) appSettingHistory {
+	_appSettingHistory := appSettingHistory{}
+
+	_appSettingHistory.appSettingHistoryDo.UseDB(db, opts...)
+	_appSettingHistory.appSettingHistoryDo.UseModel(&models.AppSettingHistory{})
+
+	tableName := _appSettingHistory.appSettingHistoryDo.TableName()
+	_appSettingHistory.ALL = field.NewAsterisk(tableName)
+	_appSettingHistory.ID = field.NewInt64(tableName, "id")
+	_appSettingHistory.Key = field.NewString(tableName, "key")
+	_appSettingHistory.OldValue = field.NewString(tableName, "old_value")
+	_appSettingHistory.NewValue = field.NewString(tableName, "new_value")
+	_appSettingHistory.Operation = field.NewString(tableName, "operation")
+	_appSettingHistory.ChangedAt = field.NewTime(tableName, "changed_at")
+	_appSettingHistory.User = field.NewString(tableName, "user")
+	_appSettingHistory.Hostname = field.NewString(tableName, "hostname")
+	_appSettingHistory.Reason = field.NewString(tableName, "reason")
+	_appSettingHistory.OldSaved = field.NewBool(tableName, "old_saved")
+	_appSettingHistory.NewSaved = field.NewBool(tableName, "new_saved")
+
+	_appSettingHistory.fillFieldMap()
+
+	return _appSettingHistory
+}
+
+type appSettingHistory struct {
+	appSettingHistoryDo
+
+	ALL       field.Asterisk
+	ID        field.Int64
+	Key       field.String
+	OldValue  field.String
+	NewValue  field.String
+	Operation field.String
+	ChangedAt field.Time
+	User      field.String
+	Hostname  field.String
+	Reason    field.String
+	OldSaved  field.Bool
+	NewSaved  field.Bool
+
+	fieldMap map[string]field.Expr
+}
+
+func (a appSettingHistory) Table(newTableName string) *appSettingHistory {
+	a.appSettingHistoryDo.UseTable(newTableName)
+	return a.updateTableName(newTableName)
+}
+
+func (a appSettingHistory) As(alias string) *appSettingHistory {
+	a.appSettingHistoryDo.DO = *(a.appSettingHistoryDo.As(alias).(*gen.DO))
+	return a.updateTableName(alias)
+}
+
+func (a *appSettingHistory) updateTableName(table string) *appSettingHistory {
+	a.ALL = field.NewAsterisk(table)
+	a.ID = field.NewInt64(table, "id")
+	a.Key = field.NewString(table, "key")
+	a.OldValue = field.NewString(table, "old_value")
+	a.NewValue = field.NewString(table, "new_value")
+	a.Operation = field.NewString(table, "operation")
+	a.ChangedAt = field.NewTime(table, "changed_at")
+	a.User = field.NewString(table, "user")
+	a.Hostname = field.NewString(table, "hostname")
+	a.Reason = field.NewString(table, "reason")
+	a.OldSaved = field.NewBool(table, "old_saved")
+	a.NewSaved = field.NewBool(table, "new_saved")
+
+	a.fillFieldMap()
+
+	return a
+}
+
+func (a appSettingHistory) Columns(cols ...field.Expr) gen.Columns {
+	return a.appSettingHistoryDo.Columns(cols...)
+}
+
+func (a *appSettingHistory) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := a.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (a *appSettingHistory) fillFieldMap() {
+	a.fieldMap = make(map[string]field.Expr, 11)
+	a.fieldMap["id"] = a.ID
+	a.fieldMap["key"] = a.Key
+	a.fieldMap["old_value"] = a.OldValue
+	a.fieldMap["new_value"] = a.NewValue
+	a.fieldMap["operation"] = a.Operation
+	a.fieldMap["changed_at"] = a.ChangedAt
+	a.fieldMap["user"] = a.User
+	a.fieldMap["hostname"] = a.Hostname
+	a.fieldMap["reason"] = a.Reason
+	a.fieldMap["old_saved"] = a.OldSaved
+	a.fieldMap["new_saved"] = a.NewSaved
+}
+
+func (a appSettingHistory) clone(db *gorm.DB) appSettingHistory {
+	a.appSettingHistoryDo.ReplaceConnPool(db.Statement.ConnPool)
+	return a
+}
+
+func (a appSettingHistory) replaceDB(db *gorm.DB) appSettingHistory {
+	a.appSettingHistoryDo.ReplaceDB(db)
+	return a
+}
+
+type appSettingHistoryDo struct{ gen.DO }
+
+type IAppSettingHistoryDo interface {
+	gen.SubQuery
+	Debug() IAppSettingHistoryDo
+	WithContext(ctx context.Context) IAppSettingHistoryDo
+	WithResult(fc func(tx gen.Dao)) gen.ResultInfo
+	ReplaceDB(db *gorm.DB)
+	ReadDB() IAppSettingHistoryDo
+	WriteDB() IAppSettingHistoryDo
+	As(alias string) gen.Dao
+	Session(config *gorm.Session) IAppSettingHistoryDo
+	Columns(cols ...field.Expr) gen.Columns
+	Clauses(conds ...clause.Expression) IAppSettingHistoryDo
+	Not(conds ...gen.Condition) IAppSettingHistoryDo
+	Or(conds ...gen.Condition) IAppSettingHistoryDo
+	Select(conds ...field.Expr) IAppSettingHistoryDo
+	Where(conds ...gen.Condition) IAppSettingHistoryDo
+	Order(conds ...field.Expr) IAppSettingHistoryDo
+	Distinct(cols ...field.Expr) IAppSettingHistoryDo
+	Omit(cols ...field.Expr) IAppSettingHistoryDo
+	Join(table schema.Tabler, on ...field.Expr) IAppSettingHistoryDo
+	LeftJoin(table schema.Tabler, on ...field.Expr) IAppSettingHistoryDo
+	RightJoin(table schema.Tabler, on ...field.Expr) IAppSettingHistoryDo
+	Group(cols ...field.Expr) IAppSettingHistoryDo
+	Having(conds ...gen.Condition) IAppSettingHistoryDo
+	Limit(limit int) IAppSettingHistoryDo
+	Offset(offset int) IAppSettingHistoryDo
+	Count() (count int64, err error)
+	Scopes(funcs ...func(gen.Dao) gen.Dao) IAppSettingHistoryDo
+	Unscoped() IAppSettingHistoryDo
+	Create(values ...*models.AppSettingHistory) error
+	CreateInBatches(values []*models.AppSettingHistory, batchSize int) error
+	Save(values ...*models.AppSettingHistory) error
+	First() (*models.AppSettingHistory, error)
+	Take() (*models.AppSettingHistory, error)
+	Last() (*models.AppSettingHistory, error)
+	Find() ([]*models.AppSettingHistory, error)
+	FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*models.AppSettingHistory, err error)
+	FindInBatches(result *[]*models.AppSettingHistory, batchSize int, fc func(tx gen.Dao, batch int) error) error
+	Pluck(column field.Expr, dest interface{}) error
+	Delete(...*models.AppSettingHistory) (info gen.ResultInfo, err error)
+	Update(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	Updates(value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumn(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumnSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	UpdateColumns(value interface{}) (info gen.ResultInfo, err error)
+	UpdateFrom(q gen.SubQuery) gen.Dao
+	Attrs(attrs ...field.AssignExpr) IAppSettingHistoryDo
+	Assign(attrs ...field.AssignExpr) IAppSettingHistoryDo
+	Joins(fields ...field.RelationField) IAppSettingHistoryDo
+	Preload(fields ...field.RelationField) IAppSettingHistoryDo
+	FirstOrInit() (*models.AppSettingHistory, error)
+	FirstOrCreate() (*models.AppSettingHistory, error)
+	FindByPage(offset int, limit int) (result []*models.AppSettingHistory, count int64, err error)
+	ScanByPage(result interface{}, offset int, limit int) (count int64, err error)
+	Scan(result interface{}) (err error)
+	Returning(value interface{}, columns ...string) IAppSettingHistoryDo
+	UnderlyingDB() *gorm.DB
+	schema.Tabler
+}
+
+func (a appSettingHistoryDo) Debug() IAppSettingHistoryDo {
+	return a.withDO(a.DO.Debug())
+}
+
+func (a appSettingHistoryDo) WithContext(ctx context.Context) IAppSettingHistoryDo {
+	return a.withDO(a.DO.WithContext(ctx))
+}
+
+func (a appSettingHistoryDo) ReadDB() IAppSettingHistoryDo {
+	return a.Clauses(dbresolver.Read)
+}
+
+func (a appSettingHistoryDo) WriteDB() IAppSettingHistoryDo {
+	return a.Clauses(dbresolver.Write)
+}
+
+func (a appSettingHistoryDo) Session(config *gorm.Session) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Session(config))
+}
+
+func (a appSettingHistoryDo) Clauses(conds ...clause.Expression) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Clauses(conds...))
+}
+
+func (a appSettingHistoryDo) Returning(value interface{}, columns ...string) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Returning(value, columns...))
+}
+
+func (a appSettingHistoryDo) Not(conds ...gen.Condition) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Not(conds...))
+}
+
+func (a appSettingHistoryDo) Or(conds ...gen.Condition) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Or(conds...))
+}
+
+func (a appSettingHistoryDo) Select(conds ...field.Expr) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Select(conds...))
+}
+
+func (a appSettingHistoryDo) Where(conds ...gen.Condition) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Where(conds...))
+}
+
+func (a appSettingHistoryDo) Order(conds ...field.Expr) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Order(conds...))
+}
+
+func (a appSettingHistoryDo) Distinct(cols ...field.Expr) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Distinct(cols...))
+}
+
+func (a appSettingHistoryDo) Omit(cols ...field.Expr) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Omit(cols...))
+}
+
+func (a appSettingHistoryDo) Join(table schema.Tabler, on ...field.Expr) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Join(table, on...))
+}
+
+func (a appSettingHistoryDo) LeftJoin(table schema.Tabler, on ...field.Expr) IAppSettingHistoryDo {
+	return a.withDO(a.DO.LeftJoin(table, on...))
+}
+
+func (a appSettingHistoryDo) RightJoin(table schema.Tabler, on ...field.Expr) IAppSettingHistoryDo {
+	return a.withDO(a.DO.RightJoin(table, on...))
+}
+
+func (a appSettingHistoryDo) Group(cols ...field.Expr) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Group(cols...))
+}
+
+func (a appSettingHistoryDo) Having(conds ...gen.Condition) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Having(conds...))
+}
+
+func (a appSettingHistoryDo) Limit(limit int) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Limit(limit))
+}
+
+func (a appSettingHistoryDo) Offset(offset int) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Offset(offset))
+}
+
+func (a appSettingHistoryDo) Scopes(funcs ...func(gen.Dao) gen.Dao) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Scopes(funcs...))
+}
+
+func (a appSettingHistoryDo) Unscoped() IAppSettingHistoryDo {
+	return a.withDO(a.DO.Unscoped())
+}
+
+func (a appSettingHistoryDo) Create(values ...*models.AppSettingHistory) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return a.DO.Create(values)
+}
+
+func (a appSettingHistoryDo) CreateInBatches(values []*models.AppSettingHistory, batchSize int) error {
+	return a.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (a appSettingHistoryDo) Save(values ...*models.AppSettingHistory) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return a.DO.Save(values)
+}
+
+func (a appSettingHistoryDo) First() (*models.AppSettingHistory, error) {
+	if result, err := a.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*models.AppSettingHistory), nil
+	}
+}
+
+func (a appSettingHistoryDo) Take() (*models.AppSettingHistory, error) {
+	if result, err := a.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*models.AppSettingHistory), nil
+	}
+}
+
+func (a appSettingHistoryDo) Last() (*models.AppSettingHistory, error) {
+	if result, err := a.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*models.AppSettingHistory), nil
+	}
+}
+
+func (a appSettingHistoryDo) Find() ([]*models.AppSettingHistory, error) {
+	result, err := a.DO.Find()
+	return result.([]*models.AppSettingHistory), err
+}
+
+func (a appSettingHistoryDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*models.AppSettingHistory, err error) {
+	buf := make([]*models.AppSettingHistory, 0, batchSize)
+	err = a.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (a appSettingHistoryDo) FindInBatches(result *[]*models.AppSettingHistory, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return a.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (a appSettingHistoryDo) Attrs(attrs ...field.AssignExpr) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Attrs(attrs...))
+}
+
+func (a appSettingHistoryDo) Assign(attrs ...field.AssignExpr) IAppSettingHistoryDo {
+	return a.withDO(a.DO.Assign(attrs...))
+}
+
+func (a appSettingHistoryDo) Joins(fields ...field.RelationField) IAppSettingHistoryDo {
+	for _, _f := range fields {
+		a = *a.withDO(a.DO.Joins(_f))
+	}
+	return &a
+}
+
+func (a appSettingHistoryDo) Preload(fields ...field.RelationField) IAppSettingHistoryDo {
+	for _, _f := range fields {
+		a = *a.withDO(a.DO.Preload(_f))
+	}
+	return &a
+}
+
+func (a appSettingHistoryDo) FirstOrInit() (*models.AppSettingHistory, error) {
+	if result, err := a.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*models.AppSettingHistory), nil
+	}
+}
+
+func (a appSettingHistoryDo) FirstOrCreate() (*models.AppSettingHistory, error) {
+	if result, err := a.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*models.AppSettingHistory), nil
+	}
+}
+
+func (a appSettingHistoryDo) FindByPage(offset int, limit int) (result []*models.AppSettingHistory, count int64, err error) {
+	result, err = a.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = a.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (a appSettingHistoryDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = a.Count()
+	if err != nil {
+		return
+	}
+
+	err = a.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (a appSettingHistoryDo) Scan(result interface{}) (err error) {
+	return a.DO.Scan(result)
+}
+
+func (a appSettingHistoryDo) Delete(models ...*models.AppSettingHistory) (result gen.ResultInfo, err error) {
+	return a.DO.Delete(models)
+}
+
+func (a *appSettingHistoryDo) withDO(do gen.Dao) *appSettingHistoryDo {
+	a.DO = *do.(*gen.DO)
+	return a
+}
@@ -0,0 +1,383 @@
+package db
+
+import (
+	"context"
+	"github.com/dan-sherwin/go-app-settings/db/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+
+	"gorm.io/plugin/dbresolver"
+)
+
+func newAppSettingScoped(db *gorm.DB, opts ...gen.DOOption) appSettingScoped {
+	_appSettingScoped := appSettingScoped{}
+
+	_appSettingScoped.appSettingScopedDo.UseDB(db, opts...)
+	_appSettingScoped.appSettingScopedDo.UseModel(&models.AppSettingScoped{})
+
+	tableName := _appSettingScoped.appSettingScopedDo.TableName()
+	_appSettingScoped.ALL = field.NewAsterisk(tableName)
+	_appSettingScoped.Key = field.NewString(tableName, "key")
+	_appSettingScoped.Scope = field.NewString(tableName, "scope")
+	_appSettingScoped.Value = field.NewString(tableName, "value")
+
+	_appSettingScoped.fillFieldMap()
+
+	return _appSettingScoped
+}
+
+type appSettingScoped struct {
+	appSettingScopedDo
+
+	ALL   field.Asterisk
+	Key   field.String
+	Scope field.String
+	Value field.String
+
+	fieldMap map[string]field.Expr
+}
+
+func (a appSettingScoped) Table(newTableName string) *appSettingScoped {
+	a.appSettingScopedDo.UseTable(newTableName)
+	return a.updateTableName(newTableName)
+}
+
+func (a appSettingScoped) As(alias string) *appSettingScoped {
+	a.appSettingScopedDo.DO = *(a.appSettingScopedDo.As(alias).(*gen.DO))
+	return a.updateTableName(alias)
+}
+
+func (a *appSettingScoped) updateTableName(table string) *appSettingScoped {
+	a.ALL = field.NewAsterisk(table)
+	a.Key = field.NewString(table, "key")
+	a.Scope = field.NewString(table, "scope")
+	a.Value = field.NewString(table, "value")
+
+	a.fillFieldMap()
+
+	return a
+}
+
+func (a *appSettingScoped) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
+	_f, ok := a.fieldMap[fieldName]
+	if !ok || _f == nil {
+		return nil, false
+	}
+	_oe, ok := _f.(field.OrderExpr)
+	return _oe, ok
+}
+
+func (a *appSettingScoped) fillFieldMap() {
+	a.fieldMap = make(map[string]field.Expr, 3)
+	a.fieldMap["key"] = a.Key
+	a.fieldMap["scope"] = a.Scope
+	a.fieldMap["value"] = a.Value
+}
+
+func (a appSettingScoped) clone(db *gorm.DB) appSettingScoped {
+	a.appSettingScopedDo.ReplaceConnPool(db.Statement.ConnPool)
+	return a
+}
+
+func (a appSettingScoped) replaceDB(db *gorm.DB) appSettingScoped {
+	a.appSettingScopedDo.ReplaceDB(db)
+	return a
+}
+
+type appSettingScopedDo struct{ gen.DO }
+
+type IAppSettingScopedDo interface {
+	gen.SubQuery
+	Debug() IAppSettingScopedDo
+	WithContext(ctx context.Context) IAppSettingScopedDo
+	WithResult(fc func(tx gen.Dao)) gen.ResultInfo
+	ReplaceDB(db *gorm.DB)
+	ReadDB() IAppSettingScopedDo
+	WriteDB() IAppSettingScopedDo
+	As(alias string) gen.Dao
+	Session(config *gorm.Session) IAppSettingScopedDo
+	Columns(cols ...field.Expr) gen.Columns
+	Clauses(conds ...clause.Expression) IAppSettingScopedDo
+	Not(conds ...gen.Condition) IAppSettingScopedDo
+	Or(conds ...gen.Condition) IAppSettingScopedDo
+	Select(conds ...field.Expr) IAppSettingScopedDo
+	Where(conds ...gen.Condition) IAppSettingScopedDo
+	Order(conds ...field.Expr) IAppSettingScopedDo
+	Distinct(cols ...field.Expr) IAppSettingScopedDo
+	Omit(cols ...field.Expr) IAppSettingScopedDo
+	Join(table schema.Tabler, on ...field.Expr) IAppSettingScopedDo
+	LeftJoin(table schema.Tabler, on ...field.Expr) IAppSettingScopedDo
+	RightJoin(table schema.Tabler, on ...field.Expr) IAppSettingScopedDo
+	Group(cols ...field.Expr) IAppSettingScopedDo
+	Having(conds ...gen.Condition) IAppSettingScopedDo
+	Limit(limit int) IAppSettingScopedDo
+	Offset(offset int) IAppSettingScopedDo
+	Count() (count int64, err error)
+	Scopes(funcs ...func(gen.Dao) gen.Dao) IAppSettingScopedDo
+	Unscoped() IAppSettingScopedDo
+	Create(values ...*models.AppSettingScoped) error
+	CreateInBatches(values []*models.AppSettingScoped, batchSize int) error
+	Save(values ...*models.AppSettingScoped) error
+	First() (*models.AppSettingScoped, error)
+	Take() (*models.AppSettingScoped, error)
+	Last() (*models.AppSettingScoped, error)
+	Find() ([]*models.AppSettingScoped, error)
+	FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*models.AppSettingScoped, err error)
+	FindInBatches(result *[]*models.AppSettingScoped, batchSize int, fc func(tx gen.Dao, batch int) error) error
+	Pluck(column field.Expr, dest interface{}) error
+	Delete(...*models.AppSettingScoped) (info gen.ResultInfo, err error)
+	Update(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	Updates(value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumn(column field.Expr, value interface{}) (info gen.ResultInfo, err error)
+	UpdateColumnSimple(columns ...field.AssignExpr) (info gen.ResultInfo, err error)
+	UpdateColumns(value interface{}) (info gen.ResultInfo, err error)
+	UpdateFrom(q gen.SubQuery) gen.Dao
+	Attrs(attrs ...field.AssignExpr) IAppSettingScopedDo
+	Assign(attrs ...field.AssignExpr) IAppSettingScopedDo
+	Joins(fields ...field.RelationField) IAppSettingScopedDo
+	Preload(fields ...field.RelationField) IAppSettingScopedDo
+	FirstOrInit() (*models.AppSettingScoped, error)
+	FirstOrCreate() (*models.AppSettingScoped, error)
+	FindByPage(offset int, limit int) (result []*models.AppSettingScoped, count int64, err error)
+	ScanByPage(result interface{}, offset int, limit int) (count int64, err error)
+	Scan(result interface{}) (err error)
+	Returning(value interface{}, columns ...string) IAppSettingScopedDo
+	UnderlyingDB() *gorm.DB
+	schema.Tabler
+}
+
+func (a appSettingScopedDo) Debug() IAppSettingScopedDo {
+	return a.withDO(a.DO.Debug())
+}
+
+func (a appSettingScopedDo) WithContext(ctx context.Context) IAppSettingScopedDo {
+	return a.withDO(a.DO.WithContext(ctx))
+}
+
+func (a appSettingScopedDo) ReadDB() IAppSettingScopedDo {
+	return a.Clauses(dbresolver.Read)
+}
+
+func (a appSettingScopedDo) WriteDB() IAppSettingScopedDo {
+	return a.Clauses(dbresolver.Write)
+}
+
+func (a appSettingScopedDo) Session(config *gorm.Session) IAppSettingScopedDo {
+	return a.withDO(a.DO.Session(config))
+}
+
+func (a appSettingScopedDo) Clauses(conds ...clause.Expression) IAppSettingScopedDo {
+	return a.withDO(a.DO.Clauses(conds...))
+}
+
+func (a appSettingScopedDo) Returning(value interface{}, columns ...string) IAppSettingScopedDo {
+	return a.withDO(a.DO.Returning(value, columns...))
+}
+
+func (a appSettingScopedDo) Not(conds ...gen.Condition) IAppSettingScopedDo {
+	return a.withDO(a.DO.Not(conds...))
+}
+
+func (a appSettingScopedDo) Or(conds ...gen.Condition) IAppSettingScopedDo {
+	return a.withDO(a.DO.Or(conds...))
+}
+
+func (a appSettingScopedDo) Select(conds ...field.Expr) IAppSettingScopedDo {
+	return a.withDO(a.DO.Select(conds...))
+}
+
+func (a appSettingScopedDo) Where(conds ...gen.Condition) IAppSettingScopedDo {
+	return a.withDO(a.DO.Where(conds...))
+}
+
+func (a appSettingScopedDo) Order(conds ...field.Expr) IAppSettingScopedDo {
+	return a.withDO(a.DO.Order(conds...))
+}
+
+func (a appSettingScopedDo) Distinct(cols ...field.Expr) IAppSettingScopedDo {
+	return a.withDO(a.DO.Distinct(cols...))
+}
+
+func (a appSettingScopedDo) Omit(cols ...field.Expr) IAppSettingScopedDo {
+	return a.withDO(a.DO.Omit(cols...))
+}
+
+func (a appSettingScopedDo) Join(table schema.Tabler, on ...field.Expr) IAppSettingScopedDo {
+	return a.withDO(a.DO.Join(table, on...))
+}
+
+func (a appSettingScopedDo) LeftJoin(table schema.Tabler, on ...field.Expr) IAppSettingScopedDo {
+	return a.withDO(a.DO.LeftJoin(table, on...))
+}
+
+func (a appSettingScopedDo) RightJoin(table schema.Tabler, on ...field.Expr) IAppSettingScopedDo {
+	return a.withDO(a.DO.RightJoin(table, on...))
+}
+
+func (a appSettingScopedDo) Group(cols ...field.Expr) IAppSettingScopedDo {
+	return a.withDO(a.DO.Group(cols...))
+}
+
+func (a appSettingScopedDo) Having(conds ...gen.Condition) IAppSettingScopedDo {
+	return a.withDO(a.DO.Having(conds...))
+}
+
+func (a appSettingScopedDo) Limit(limit int) IAppSettingScopedDo {
+	return a.withDO(a.DO.Limit(limit))
+}
+
+func (a appSettingScopedDo) Offset(offset int) IAppSettingScopedDo {
+	return a.withDO(a.DO.Offset(offset))
+}
+
+func (a appSettingScopedDo) Scopes(funcs ...func(gen.Dao) gen.Dao) IAppSettingScopedDo {
+	return a.withDO(a.DO.Scopes(funcs...))
+}
+
+func (a appSettingScopedDo) Unscoped() IAppSettingScopedDo {
+	return a.withDO(a.DO.Unscoped())
+}
+
+func (a appSettingScopedDo) Create(values ...*models.AppSettingScoped) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return a.DO.Create(values)
+}
+
+func (a appSettingScopedDo) CreateInBatches(values []*models.AppSettingScoped, batchSize int) error {
+	return a.DO.CreateInBatches(values, batchSize)
+}
+
+// Save : !!! underlying implementation is different with GORM
+// The method is equivalent to executing the statement: db.Clauses(clause.OnConflict{UpdateAll: true}).Create(values)
+func (a appSettingScopedDo) Save(values ...*models.AppSettingScoped) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return a.DO.Save(values)
+}
+
+func (a appSettingScopedDo) First() (*models.AppSettingScoped, error) {
+	if result, err := a.DO.First(); err != nil {
+		return nil, err
+	} else {
+		return result.(*models.AppSettingScoped), nil
+	}
+}
+
+func (a appSettingScopedDo) Take() (*models.AppSettingScoped, error) {
+	if result, err := a.DO.Take(); err != nil {
+		return nil, err
+	} else {
+		return result.(*models.AppSettingScoped), nil
+	}
+}
+
+func (a appSettingScopedDo) Last() (*models.AppSettingScoped, error) {
+	if result, err := a.DO.Last(); err != nil {
+		return nil, err
+	} else {
+		return result.(*models.AppSettingScoped), nil
+	}
+}
+
+func (a appSettingScopedDo) Find() ([]*models.AppSettingScoped, error) {
+	result, err := a.DO.Find()
+	return result.([]*models.AppSettingScoped), err
+}
+
+func (a appSettingScopedDo) FindInBatch(batchSize int, fc func(tx gen.Dao, batch int) error) (results []*models.AppSettingScoped, err error) {
+	buf := make([]*models.AppSettingScoped, 0, batchSize)
+	err = a.DO.FindInBatches(&buf, batchSize, func(tx gen.Dao, batch int) error {
+		defer func() { results = append(results, buf...) }()
+		return fc(tx, batch)
+	})
+	return results, err
+}
+
+func (a appSettingScopedDo) FindInBatches(result *[]*models.AppSettingScoped, batchSize int, fc func(tx gen.Dao, batch int) error) error {
+	return a.DO.FindInBatches(result, batchSize, fc)
+}
+
+func (a appSettingScopedDo) Attrs(attrs ...field.AssignExpr) IAppSettingScopedDo {
+	return a.withDO(a.DO.Attrs(attrs...))
+}
+
+func (a appSettingScopedDo) Assign(attrs ...field.AssignExpr) IAppSettingScopedDo {
+	return a.withDO(a.DO.Assign(attrs...))
+}
+
+func (a appSettingScopedDo) Joins(fields ...field.RelationField) IAppSettingScopedDo {
+	for _, _f := range fields {
+		a = *a.withDO(a.DO.Joins(_f))
+	}
+	return &a
+}
+
+func (a appSettingScopedDo) Preload(fields ...field.RelationField) IAppSettingScopedDo {
+	for _, _f := range fields {
+		a = *a.withDO(a.DO.Preload(_f))
+	}
+	return &a
+}
+
+func (a appSettingScopedDo) FirstOrInit() (*models.AppSettingScoped, error) {
+	if result, err := a.DO.FirstOrInit(); err != nil {
+		return nil, err
+	} else {
+		return result.(*models.AppSettingScoped), nil
+	}
+}
+
+func (a appSettingScopedDo) FirstOrCreate() (*models.AppSettingScoped, error) {
+	if result, err := a.DO.FirstOrCreate(); err != nil {
+		return nil, err
+	} else {
+		return result.(*models.AppSettingScoped), nil
+	}
+}
+
+func (a appSettingScopedDo) FindByPage(offset int, limit int) (result []*models.AppSettingScoped, count int64, err error) {
+	result, err = a.Offset(offset).Limit(limit).Find()
+	if err != nil {
+		return
+	}
+
+	if size := len(result); 0 < limit && 0 < size && size < limit {
+		count = int64(size + offset)
+		return
+	}
+
+	count, err = a.Offset(-1).Limit(-1).Count()
+	return
+}
+
+func (a appSettingScopedDo) ScanByPage(result interface{}, offset int, limit int) (count int64, err error) {
+	count, err = a.Count()
+	if err != nil {
+		return
+	}
+
+	err = a.Offset(offset).Limit(limit).Scan(result)
+	return
+}
+
+func (a appSettingScopedDo) Scan(result interface{}) (err error) {
+	return a.DO.Scan(result)
+}
+
+func (a appSettingScopedDo) Delete(models ...*models.AppSettingScoped) (result gen.ResultInfo, err error) {
+	return a.DO.Delete(models)
+}
+
+func (a *appSettingScopedDo) withDO(do gen.Dao) *appSettingScopedDo {
+	a.DO = *do.(*gen.DO)
+	return a
+}
@@ -16,9 +16,11 @@ import (
 )
 
 var (
-	Q          = new(Query)
-	AppSetting *appSetting
-	DB         *gorm.DB
+	Q                 = new(Query)
+	AppSetting        *appSetting
+	AppSettingHistory *appSettingHistory
+	AppSettingScoped  *appSettingScoped
+	DB                *gorm.DB
 )
 
 func DBInit(fileName string) error {
@@ -26,34 +28,123 @@ func DBInit(fileName string) error {
 }
 
 func DBInitWithTable(fileName string, tableName string) error {
-	var err error
-	DB, err = gorm.Open(sqlite.Open(fileName), &gorm.Config{})
+	store, err := NewStore(fileName, tableName)
 	if err != nil {
 		return err
 	}
-	return DBInitWithDB(DB, tableName)
+	UseStore(store)
+	return nil
 }
 
 func DBInitWithDB(gormDB *gorm.DB, tableName string) error {
+	store, err := NewStoreWithDB(gormDB, tableName)
+	if err != nil {
+		return err
+	}
+	UseStore(store)
+	return nil
+}
+
+// Store bundles a database handle with the query objects bound to a single settings table and its history table.
+// Unlike the package-level DB, Q and AppSetting variables, any number of stores can be open at once.
+type Store struct {
+	DB                *gorm.DB
+	Q                 *Query
+	AppSetting        *appSetting
+	AppSettingHistory *appSettingHistory
+	AppSettingScoped  *appSettingScoped
+	TableName         string
+}
+
+// HistoryTableName returns the name of the history table that belongs to the settings table tableName.
+func HistoryTableName(tableName string) string {
+	return tableName + "_history"
+}
+
+// ScopedTableName returns the name of the table of scoped overrides that belongs to the settings table tableName.
+func ScopedTableName(tableName string) string {
+	return tableName + "_scoped"
+}
+
+// NewStore opens (or creates) the SQLite database at fileName and prepares the settings table.
+func NewStore(fileName string, tableName string) (*Store, error) {
+	return NewStoreContext(context.Background(), fileName, tableName)
+}
+
+// NewStoreContext is NewStore with a context bounding the preparation of the tables.
+func NewStoreContext(ctx context.Context, fileName string, tableName string) (*Store, error) {
+	gormDB, err := gorm.Open(sqlite.Open(fileName), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	return NewStoreWithDBContext(ctx, gormDB, tableName)
+}
+
+// NewStoreWithDB prepares the settings table inside an existing database.
+func NewStoreWithDB(gormDB *gorm.DB, tableName string) (*Store, error) {
+	return NewStoreWithDBContext(context.Background(), gormDB, tableName)
+}
+
+// NewStoreWithDBContext is NewStoreWithDB with a context bounding the ping and the table migrations. The
+// returned store is not bound to ctx; use WithContext for that.
+func NewStoreWithDBContext(ctx context.Context, gormDB *gorm.DB, tableName string) (*Store, error) {
 	if gormDB == nil {
-		return fmt.Errorf("database cannot be nil")
+		return nil, fmt.Errorf("database cannot be nil")
 	}
 	if tableName == "" {
 		tableName = models.TableNameAppSetting
 	}
-	DB = gormDB
-	sqldb, err := DB.DB()
+	sqldb, err := gormDB.DB()
 	if err != nil {
-		return fmt.Errorf("unable to get database handle: %w", err)
+		return nil, fmt.Errorf("unable to get database handle: %w", err)
 	}
-	if err := sqldb.Ping(); err != nil {
-		return fmt.Errorf("unable to ping database: %w", err)
+	if err := sqldb.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("unable to ping database: %w", err)
 	}
-	if err := ensureAppSettingsTable(DB, tableName); err != nil {
-		return err
+	ctxDB := gormDB.WithContext(ctx)
+	if err := ensureAppSettingsTable(ctxDB, tableName); err != nil {
+		return nil, err
 	}
-	SetDefaultTable(DB, tableName)
-	return nil
+	if err := ctxDB.Table(HistoryTableName(tableName)).AutoMigrate(&models.AppSettingHistory{}); err != nil {
+		return nil, fmt.Errorf("migrate %s table: %w", HistoryTableName(tableName), err)
+	}
+	if err := ctxDB.Table(ScopedTableName(tableName)).AutoMigrate(&models.AppSettingScoped{}); err != nil {
+		return nil, fmt.Errorf("migrate %s table: %w", ScopedTableName(tableName), err)
+	}
+	return newStore(gormDB, Use(gormDB), tableName), nil
+}
+
+func newStore(gormDB *gorm.DB, q *Query, tableName string) *Store {
+	return &Store{
+		DB:                gormDB,
+		Q:                 q,
+		AppSetting:        q.AppSetting.Table(tableName),
+		AppSettingHistory: q.AppSettingHistory.Table(HistoryTableName(tableName)),
+		AppSettingScoped:  q.AppSettingScoped.Table(ScopedTableName(tableName)),
+		TableName:         tableName,
+	}
+}
+
+// UseStore makes store the target of the package-level DB, Q and AppSetting variables.
+func UseStore(store *Store) {
+	DB = store.DB
+	*Q = *store.Q
+	AppSetting = store.AppSetting
+	AppSettingHistory = store.AppSettingHistory
+	AppSettingScoped = store.AppSettingScoped
+}
+
+// WithContext returns a Store on the same tables whose queries and transactions use ctx.
+func (s *Store) WithContext(ctx context.Context) *Store {
+	ctxDB := s.DB.WithContext(ctx)
+	return newStore(ctxDB, s.Q.ReplaceDB(ctxDB), s.TableName)
+}
+
+// Transaction runs fc inside a database transaction with a Store bound to the same table.
+func (s *Store) Transaction(fc func(tx *Store) error, opts ...*sql.TxOptions) error {
+	return s.Q.Transaction(func(q *Query) error {
+		return fc(newStore(q.db, q, s.TableName))
+	}, opts...)
 }
 
 func ensureAppSettingsTable(gormDB *gorm.DB, tableName string) error {
@@ -98,27 +189,35 @@ func SetDefaultTable(db *gorm.DB, tableName string, opts ...gen.DOOption) {
 	}
 	*Q = *Use(db, opts...)
 	AppSetting = Q.AppSetting.Table(tableName)
+	AppSettingHistory = Q.AppSettingHistory.Table(HistoryTableName(tableName))
+	AppSettingScoped = Q.AppSettingScoped.Table(ScopedTableName(tableName))
 }
 
 func Use(db *gorm.DB, opts ...gen.DOOption) *Query {
 	return &Query{
-		db:         db,
-		AppSetting: newAppSetting(db, opts...),
+		db:                db,
+		AppSetting:        newAppSetting(db, opts...),
+		AppSettingHistory: newAppSettingHistory(db, opts...),
+		AppSettingScoped:  newAppSettingScoped(db, opts...),
 	}
 }
 
 type Query struct {
 	db *gorm.DB
 
-	AppSetting appSetting
+	AppSetting        appSetting
+	AppSettingHistory appSettingHistory
+	AppSettingScoped  appSettingScoped
 }
 
 func (q *Query) Available() bool { return q.db != nil }
 
 func (q *Query) clone(db *gorm.DB) *Query {
 	return &Query{
-		db:         db,
-		AppSetting: q.AppSetting.clone(db),
+		db:                db,
+		AppSetting:        q.AppSetting.clone(db),
+		AppSettingHistory: q.AppSettingHistory.clone(db),
+		AppSettingScoped:  q.AppSettingScoped.clone(db),
 	}
 }
 
@@ -132,18 +231,24 @@ func (q *Query) WriteDB() *Query {
 
 func (q *Query) ReplaceDB(db *gorm.DB) *Query {
 	return &Query{
-		db:         db,
-		AppSetting: q.AppSetting.replaceDB(db),
+		db:                db,
+		AppSetting:        q.AppSetting.replaceDB(db),
+		AppSettingHistory: q.AppSettingHistory.replaceDB(db),
+		AppSettingScoped:  q.AppSettingScoped.replaceDB(db),
 	}
 }
 
 type queryCtx struct {
-	AppSetting IAppSettingDo
+	AppSetting        IAppSettingDo
+	AppSettingHistory IAppSettingHistoryDo
+	AppSettingScoped  IAppSettingScopedDo
 }
 
 func (q *Query) WithContext(ctx context.Context) *queryCtx {
 	return &queryCtx{
-		AppSetting: q.AppSetting.WithContext(ctx),
+		AppSetting:        q.AppSetting.WithContext(ctx),
+		AppSettingHistory: q.AppSettingHistory.WithContext(ctx),
+		AppSettingScoped:  q.AppSettingScoped.WithContext(ctx),
 	}
 }
 
@@ -5,7 +5,9 @@ const TableNameAppSetting = "app_settings"
 type AppSetting struct {
 	Key         string `gorm:"column:key;type:TEXT;primaryKey" json:"key"`
 	Value       string `gorm:"column:value;type:TEXT;not null" json:"value"`
+	Version     int64  `gorm:"column:version;not null;default:0" json:"version"`
 	Description string `gorm:"-" json:"description"`
+	Source      string `gorm:"-" json:"source,omitempty"`
 }
 
 func (*AppSetting) TableName() string {
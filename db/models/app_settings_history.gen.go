@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+const TableNameAppSettingHistory = "app_settings_history"
+
+type AppSettingHistory struct {
+	ID        int64     `gorm:"column:id;type:INTEGER;primaryKey;autoIncrement:true" json:"id"`
+	Key       string    `gorm:"column:key;type:TEXT;not null;index" json:"key"`
+	OldValue  string    `gorm:"column:old_value;type:TEXT;not null" json:"old_value"`
+	NewValue  string    `gorm:"column:new_value;type:TEXT;not null" json:"new_value"`
+	Operation string    `gorm:"column:operation;type:TEXT;not null" json:"operation"`
+	ChangedAt time.Time `gorm:"column:changed_at;type:DATETIME;not null;index" json:"changed_at"`
+	User      string    `gorm:"column:user;type:TEXT;not null" json:"user"`
+	Hostname  string    `gorm:"column:hostname;type:TEXT;not null" json:"hostname"`
+	Reason    string    `gorm:"column:reason;type:TEXT;not null" json:"reason"`
+	OldSaved  *bool     `gorm:"column:old_saved;type:BOOLEAN" json:"old_saved"`
+	NewSaved  *bool     `gorm:"column:new_saved;type:BOOLEAN" json:"new_saved"`
+}
+
+func (*AppSettingHistory) TableName() string {
+	return TableNameAppSettingHistory
+}
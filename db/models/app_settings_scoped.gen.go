@@ -0,0 +1,13 @@
+package models
+
+const TableNameAppSettingScoped = "app_settings_scoped"
+
+type AppSettingScoped struct {
+	Key   string `gorm:"column:key;type:TEXT;primaryKey" json:"key"`
+	Scope string `gorm:"column:scope;type:TEXT;primaryKey" json:"scope"`
+	Value string `gorm:"column:value;type:TEXT;not null" json:"value"`
+}
+
+func (*AppSettingScoped) TableName() string {
+	return TableNameAppSettingScoped
+}
@@ -0,0 +1,106 @@
+package app_settings
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dan-sherwin/go-app-settings/db"
+)
+
+// rowKey identifies a stored value: a setting name and the scope of an override, empty for global values.
+type rowKey struct {
+	name  string
+	scope string
+}
+
+// storedRows returns the settings table and its scoped overrides as stored. Values are left encrypted: the
+// watcher only compares them.
+func storedRows(store *db.Store) (map[rowKey]string, error) {
+	rows, err := store.AppSetting.Find()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting saved settings: %w", err)
+	}
+	scoped, err := store.AppSettingScoped.Find()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting scoped settings: %w", err)
+	}
+	values := make(map[rowKey]string, len(rows)+len(scoped))
+	for _, row := range rows {
+		values[rowKey{name: row.Key}] = row.Value
+	}
+	for _, row := range scoped {
+		values[rowKey{name: row.Key, scope: row.Scope}] = row.Value
+	}
+	return values, nil
+}
+
+// watchDB polls the settings table every interval and reloads the settings whose rows were added, changed or
+// removed since the previous poll, starting from rows, until stop is closed. Reloaded values go through
+// SetFunc and notify subscribers like any other change; changed scoped overrides are refreshed in memory.
+// Failures are reported through reportError.
+func (r *Registry) watchDB(store *db.Store, rows map[rowKey]string, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		current, err := storedRows(store)
+		if err != nil {
+			r.reportError(fmt.Errorf("poll settings table: %w", err))
+			continue
+		}
+		changed := map[string]bool{}
+		scopedChanged := false
+		mark := func(key rowKey) {
+			if key.scope != "" {
+				scopedChanged = true
+				return
+			}
+			changed[key.name] = true
+		}
+		for key, value := range current {
+			if previous, ok := rows[key]; !ok || previous != value {
+				mark(key)
+			}
+		}
+		for key := range rows {
+			if _, ok := current[key]; !ok {
+				mark(key)
+			}
+		}
+		if len(changed) == 0 {
+			if scopedChanged {
+				// Only overrides changed: no running value does, so just refresh them.
+				if err := r.refreshScoped(store); err != nil {
+					r.reportError(err)
+					continue
+				}
+				rows = current
+			}
+			continue
+		}
+		registered := []string{}
+		for name := range changed {
+			if _, err := r.GetSetting(name); err == nil {
+				registered = append(registered, name)
+			}
+		}
+		sort.Strings(registered)
+		results, err := r.ReloadSettings(registered...)
+		if err != nil {
+			// Keep the previous rows so the next poll retries.
+			r.reportError(fmt.Errorf("reload changed settings: %w", err))
+			continue
+		}
+		rows = current
+		for _, res := range results {
+			if res.Error != "" {
+				r.reportError(fmt.Errorf("apply setting %s changed in the database: %s", res.Name, res.Error))
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package app_settings
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDBWatch_AppliesChangesFromOtherInstances(t *testing.T) {
+	t.Parallel()
+	path := tempDBPath(t)
+	var mu sync.Mutex
+	var errs []error
+	changes := make(chan Change, 10)
+	watcher := NewRegistry()
+	var region, port string
+	watcher.RegisterStringSetting("region", "Region", &region)
+	watcher.RegisterStringSetting("http.port", "HTTP port", &port, WithValidator("numeric", func(v string) error {
+		if strings.Trim(v, "0123456789") != "" {
+			return errors.New("not a number")
+		}
+		return nil
+	}))
+	watcher.OnChange("region", func(old, new string) { changes <- Change{Name: "region", Old: old, New: new} })
+	options := SettingsOptions{
+		DBWatchInterval: 10 * time.Millisecond,
+		OnError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		},
+	}
+	if err := watcher.Setup(path, options); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	defer watcher.Close()
+
+	writer := NewRegistry()
+	var otherRegion, otherPort string
+	writer.RegisterStringSetting("region", "Region", &otherRegion)
+	writer.RegisterStringSetting("http.port", "HTTP port", &otherPort)
+	if err := writer.Setup(path, SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := writer.SetSetting("region", "us"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	select {
+	case c := <-changes:
+		if c.New != "us" {
+			t.Fatalf("unexpected change %#v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("change from another instance not applied")
+	}
+	if source, _ := watcher.ValueSource("region"); source != SourceDB {
+		t.Fatalf("expected db source, got %q", source)
+	}
+
+	if err := writer.SetSetting("http.port", "http"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	waitFor(t, "the rejected value to be reported", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) > 0
+	})
+	if port != "" {
+		t.Fatalf("rejected value applied: %q", port)
+	}
+
+	if err := writer.ResetSetting("region"); err != nil {
+		t.Fatalf("ResetSetting failed: %v", err)
+	}
+	select {
+	case c := <-changes:
+		if c.New != "" {
+			t.Fatalf("unexpected change %#v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("removal from another instance not applied")
+	}
+
+	if err := watcher.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := writer.SetSetting("region", "ap"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	select {
+	case c := <-changes:
+		t.Fatalf("change applied after Close: %#v", c)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
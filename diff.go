@@ -0,0 +1,286 @@
+package app_settings
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// ErrSettingsDiffer is returned by `settings diff` when the compared values differ.
+var ErrSettingsDiffer = errors.New("settings differ")
+
+// Views of the settings that `settings diff` compares; any other name is a config file.
+const (
+	viewDefaults = "defaults"
+	viewSaved    = "saved"
+	viewRunning  = "running"
+	viewActive   = "active"
+)
+
+// settingsView is a set of setting values to compare. Partial views, the saved values and files, only hold
+// some settings; the others hold every visible setting. fallback holds the value and source a setting missing
+// from a partial view runs with instead, such as the file value or default of a setting without a saved value.
+// sealed marks the masked values of sensitive settings the running application did not reveal; a sealed value
+// only equals the same sealed value.
+type settingsView struct {
+	values   map[string]string
+	partial  bool
+	fallback map[string]layerValue
+	sealed   map[string]bool
+}
+
+// lookup returns the value of the named setting to compare with other: its value in the view, or its
+// fallback when other holds every setting.
+func (v settingsView) lookup(name string, other settingsView) (string, Source, bool) {
+	if value, ok := v.values[name]; ok {
+		return value, "", true
+	}
+	if fb, ok := v.fallback[name]; ok && !other.partial {
+		return fb.value, fb.source, true
+	}
+	return "", "", false
+}
+
+// GetRunningValues reports the running value of every visible setting keyed by name, with sensitive values
+// masked, for `settings diff`.
+func (s *settingsService) GetRunningValues(_ *struct{}, values *map[string]string) error {
+	running := map[string]string{}
+	for _, setting := range s.registry.snapshot() {
+		if !setting.Hidden {
+			running[setting.Name] = setting.display(setting.GetFunc())
+		}
+	}
+	*values = running
+	return nil
+}
+
+// CompareRunningValues reports for each visible setting in values whether its running value equals the given
+// one, so `settings diff` can compare sensitive values without the application revealing them.
+func (s *settingsService) CompareRunningValues(values *map[string]string, equal *map[string]bool) error {
+	result := map[string]bool{}
+	for name, value := range *values {
+		setting, err := s.registry.GetSetting(name)
+		if err != nil || setting.Hidden {
+			continue
+		}
+		result[name] = subtle.ConstantTimeCompare([]byte(setting.GetFunc()), []byte(value)) == 1
+	}
+	*equal = result
+	return nil
+}
+
+// unseal asks the running application whether the sealed values of running equal the values of other and
+// replaces those that do by them.
+func (r *Registry) unseal(running *settingsView, other settingsView) error {
+	values := map[string]string{}
+	for name := range running.sealed {
+		if value, _, ok := other.lookup(name, *running); ok && !other.sealed[name] {
+			values[name] = value
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	client, err := r.dialDaemon(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	equal := map[string]bool{}
+	if err := callDaemon(ctx, client, "CompareRunningValues", &values, &equal); err != nil {
+		return fmt.Errorf("Error comparing running settings: %w", err)
+	}
+	for name, same := range equal {
+		if _, asked := values[name]; same && asked {
+			running.values[name] = values[name]
+			delete(running.sealed, name)
+		}
+	}
+	return nil
+}
+
+// view returns the named view of the visible settings.
+func (r *Registry) view(name string) (settingsView, error) {
+	values := map[string]string{}
+	switch name {
+	case viewDefaults:
+		r.mu.RLock()
+		defaults := r.defaultSettings
+		r.mu.RUnlock()
+		for _, d := range defaults {
+			values[d.Key] = d.Value
+		}
+	case viewSaved:
+		store, err := r.getStore()
+		if err != nil {
+			return settingsView{}, err
+		}
+		saved, err := r.savedStates(store)
+		if err != nil {
+			return settingsView{}, err
+		}
+		for key, state := range saved {
+			values[key] = state.value
+		}
+		fallback := map[string]layerValue{}
+		for _, s := range r.snapshot() {
+			if _, ok := saved[s.Name]; ok || s.Hidden {
+				continue
+			}
+			v, ok := r.fileValue(s.Name)
+			if !ok {
+				defaultValue, _ := r.defaultValue(s.Name)
+				v = layerValue{value: defaultValue, source: SourceDefault}
+			}
+			fallback[s.Name] = v
+		}
+		return settingsView{values: r.visibleValues(values), partial: true, fallback: fallback}, nil
+	case viewActive:
+		for _, s := range r.snapshot() {
+			values[s.Name] = s.GetFunc()
+		}
+	case viewRunning:
+		ctx := context.Background()
+		client, err := r.dialDaemon(ctx)
+		if err != nil {
+			return settingsView{}, err
+		}
+		defer client.Close()
+		running := map[string]string{}
+		if err := callDaemon(ctx, client, "GetRunningValues", &struct{}{}, &running); err != nil {
+			return settingsView{}, fmt.Errorf("Error getting running settings: %w", err)
+		}
+		visible := r.visibleValues(running)
+		sealed := map[string]bool{}
+		for name, value := range visible {
+			if setting, _ := r.GetSetting(name); setting.Sensitive && value != "" {
+				sealed[name] = true
+			}
+		}
+		return settingsView{values: visible, sealed: sealed}, nil
+	default:
+		file, err := readConfigFile(name)
+		if err != nil {
+			return settingsView{}, err
+		}
+		for key, value := range file {
+			if _, err := r.GetSetting(key); err != nil {
+				return settingsView{}, fmt.Errorf("config file %s: unknown setting %s", name, key)
+			}
+			values[key] = value
+		}
+	}
+	partial := name != viewDefaults && name != viewActive
+	return settingsView{values: r.visibleValues(values), partial: partial}, nil
+}
+
+// visibleValues drops hidden and unknown settings.
+func (r *Registry) visibleValues(values map[string]string) map[string]string {
+	visible := map[string]string{}
+	for key, value := range values {
+		setting, err := r.GetSetting(key)
+		if err != nil || setting.Hidden {
+			continue
+		}
+		visible[key] = value
+	}
+	return visible
+}
+
+// settingDiff is a setting whose value differs between two views. A source is set when the value is the
+// fallback of a setting missing from its view.
+type settingDiff struct {
+	name                 string
+	from, to             *string
+	fromSource, toSource Source
+}
+
+// diffViews returns the settings whose values differ, sorted by name. A setting missing from a partial view
+// is compared through the view's fallback, and otherwise only reported when the other view is partial as well.
+func diffViews(from, to settingsView) []settingDiff {
+	names := map[string]bool{}
+	for _, v := range []settingsView{from, to} {
+		for name := range v.values {
+			names[name] = true
+		}
+	}
+	diffs := []settingDiff{}
+	for name := range names {
+		f, fromSource, inFrom := from.lookup(name, to)
+		t, toSource, inTo := to.lookup(name, from)
+		switch {
+		case inFrom && inTo && f == t && from.sealed[name] == to.sealed[name]:
+			continue
+		case !inFrom && !to.partial, !inTo && !from.partial:
+			continue
+		}
+		d := settingDiff{name: name, fromSource: fromSource, toSource: toSource}
+		if inFrom {
+			d.from = &f
+		}
+		if inTo {
+			d.to = &t
+		}
+		diffs = append(diffs, d)
+	}
+	slices.SortFunc(diffs, func(a, b settingDiff) int { return strings.Compare(a.name, b.name) })
+	return diffs
+}
+
+// Run prints the settings whose values differ between two views and fails with ErrSettingsDiffer if any do.
+func (c *SettingsDiffCommand) Run(r *Registry) error {
+	from, err := r.view(c.From)
+	if err != nil {
+		return printAndReturnErr(err)
+	}
+	to, err := r.view(c.To)
+	if err != nil {
+		return printAndReturnErr(err)
+	}
+	if c.From == viewRunning {
+		err = r.unseal(&from, to)
+	}
+	if err == nil && c.To == viewRunning {
+		err = r.unseal(&to, from)
+	}
+	if err != nil {
+		return printAndReturnErr(err)
+	}
+	diffs := diffViews(from, to)
+	if len(diffs) == 0 {
+		fmt.Println("No differences")
+		return nil
+	}
+	show := func(setting *Setting, value *string, source Source) string {
+		if value == nil {
+			return "(not set)"
+		}
+		shown := *value
+		if setting.Sensitive && shown != "" {
+			shown = maskedValue
+		}
+		if source != "" {
+			// The setting is missing from the view; show what it falls back to.
+			return fmt.Sprintf("(not set) %s from %s", shown, source)
+		}
+		return shown
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header([]string{"Setting", c.From, c.To})
+	for _, d := range diffs {
+		setting, err := r.GetSetting(d.name)
+		if err != nil {
+			return printAndReturnErr(err)
+		}
+		table.Append([]string{d.name, show(setting, d.from, d.fromSource), show(setting, d.to, d.toSource)})
+	}
+	table.Render()
+	return fmt.Errorf("%w: %d", ErrSettingsDiffer, len(diffs))
+}
@@ -0,0 +1,170 @@
+package app_settings
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// serveRegistry serves the RPC socket of r as the running application would.
+func serveRegistry(t *testing.T, r *Registry) {
+	t.Helper()
+	if err := r.ServeRPC(RPCServerOptions{}); err != nil {
+		t.Fatalf("ServeRPC failed: %v", err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+}
+
+// shortSocketPath returns a socket path short enough for the unix socket limit.
+func shortSocketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "as")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return filepath.Join(dir, "settings.sock")
+}
+
+func TestSettingsDiff_SavedAgainstRunning(t *testing.T) {
+	path, socket := tempDBPath(t), shortSocketPath(t)
+	register := func(r *Registry, region, token *string) {
+		r.RegisterStringSetting("region", "Region", region)
+		r.RegisterSecretSetting("token", "API token", token)
+	}
+	daemon := NewRegistry()
+	var daemonRegion, daemonToken string
+	register(daemon, &daemonRegion, &daemonToken)
+	options := SettingsOptions{RpcSocketPathToListRunningSettings: socket}
+	if err := daemon.Setup(path, options); err != nil {
+		t.Fatalf("daemon setup failed: %v", err)
+	}
+	serveRegistry(t, daemon)
+
+	cli := NewRegistry()
+	var cliRegion, cliToken string
+	register(cli, &cliRegion, &cliToken)
+	if err := cli.Setup(path, options); err != nil {
+		t.Fatalf("cli setup failed: %v", err)
+	}
+	if err := cli.SetSetting("region", "us"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if err := cli.SetSetting("token", "s3cret"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+
+	var err error
+	out := captureStdout(func() {
+		err = (&SettingsDiffCommand{From: "saved", To: "running"}).Run(cli)
+	})
+	if !errors.Is(err, ErrSettingsDiffer) {
+		t.Fatalf("expected ErrSettingsDiffer, got %v", err)
+	}
+	if !strings.Contains(out, "us") || strings.Contains(out, "s3cret") || !strings.Contains(out, maskedValue) {
+		t.Fatalf("unexpected diff output: %s", out)
+	}
+
+	if err := daemon.RetrieveAppSettings(); err != nil {
+		t.Fatalf("RetrieveAppSettings failed: %v", err)
+	}
+	out = captureStdout(func() {
+		err = (&SettingsDiffCommand{From: "saved", To: "running"}).Run(cli)
+	})
+	if err != nil || !strings.Contains(out, "No differences") {
+		t.Fatalf("expected no differences after reload, got %v: %s", err, out)
+	}
+
+	// The application only tells whether a sensitive value equals the one asked about.
+	client, err := cli.dialDaemon(context.Background())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer client.Close()
+	running := map[string]string{}
+	if err := client.Call(rpcServiceName+".GetRunningValues", &struct{}{}, &running); err != nil {
+		t.Fatalf("GetRunningValues failed: %v", err)
+	}
+	if running["token"] != maskedValue || running["region"] != "us" {
+		t.Fatalf("unexpected running values: %v", running)
+	}
+	equal := map[string]bool{}
+	if err := client.Call(rpcServiceName+".CompareRunningValues", &map[string]string{"token": "guess", "region": "us"}, &equal); err != nil {
+		t.Fatalf("CompareRunningValues failed: %v", err)
+	}
+	if equal["token"] || !equal["region"] {
+		t.Fatalf("unexpected comparison: %v", equal)
+	}
+	if err := cli.SetSetting("token", "rotated"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	out = captureStdout(func() {
+		err = (&SettingsDiffCommand{From: "saved", To: "running"}).Run(cli)
+	})
+	if !errors.Is(err, ErrSettingsDiffer) || !strings.Contains(out, "token") || strings.Contains(out, "rotated") || strings.Contains(out, "s3cret") {
+		t.Fatalf("expected the rotated token to differ, masked, got %v: %s", err, out)
+	}
+	if err := daemon.RetrieveAppSettings(); err != nil {
+		t.Fatalf("RetrieveAppSettings failed: %v", err)
+	}
+
+	// A removed saved value still differs from the value the application runs with.
+	if err := cli.ResetSetting("region"); err != nil {
+		t.Fatalf("ResetSetting failed: %v", err)
+	}
+	out = captureStdout(func() {
+		err = (&SettingsDiffCommand{From: "saved", To: "running"}).Run(cli)
+	})
+	if !errors.Is(err, ErrSettingsDiffer) || !strings.Contains(out, "from default") || !strings.Contains(out, "us") {
+		t.Fatalf("expected the removed saved value to differ from the running one, got %v: %s", err, out)
+	}
+	if err := daemon.RetrieveAppSettings(); err != nil {
+		t.Fatalf("RetrieveAppSettings failed: %v", err)
+	}
+	out = captureStdout(func() {
+		err = (&SettingsDiffCommand{From: "saved", To: "running"}).Run(cli)
+	})
+	if err != nil || !strings.Contains(out, "No differences") {
+		t.Fatalf("expected no differences once the default is running, got %v: %s", err, out)
+	}
+}
+
+func TestSettingsDiff_DefaultsSavedAndFiles(t *testing.T) {
+	r := NewRegistry()
+	port, region := 8080, "eu"
+	r.RegisterIntSetting("http.port", "HTTP port", &port)
+	r.RegisterStringSetting("region", "Region", &region)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.SetSetting("http.port", "9000"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	file := writeFile(t, filepath.Join(t.TempDir(), "prod.properties"), "region=us\n")
+
+	var err error
+	out := captureStdout(func() {
+		err = (&SettingsDiffCommand{From: "defaults", To: "saved"}).Run(r)
+	})
+	if !errors.Is(err, ErrSettingsDiffer) || !strings.Contains(out, "8080") || !strings.Contains(out, "9000") || strings.Contains(out, "region") {
+		t.Fatalf("unexpected defaults/saved diff (%v): %s", err, out)
+	}
+	out = captureStdout(func() {
+		err = (&SettingsDiffCommand{From: "saved", To: file}).Run(r)
+	})
+	if !errors.Is(err, ErrSettingsDiffer) || !strings.Contains(out, "(not set)") || !strings.Contains(out, "us") {
+		t.Fatalf("unexpected saved/file diff (%v): %s", err, out)
+	}
+	out = captureStdout(func() {
+		err = (&SettingsDiffCommand{From: "saved", To: "active"}).Run(r)
+	})
+	if err != nil || !strings.Contains(out, "No differences") {
+		t.Fatalf("expected saved and active to match, got %v: %s", err, out)
+	}
+	if err := (&SettingsDiffCommand{From: "saved", To: "running"}).Run(r); err == nil || errors.Is(err, ErrSettingsDiffer) {
+		t.Fatalf("expected a connection error without a socket, got %v", err)
+	}
+}
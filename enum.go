@@ -0,0 +1,127 @@
+package app_settings
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// OneOf restricts a setting to the given values. The allowed values are shown by `settings list`, the
+// settings help output and shell completion.
+func OneOf(values ...string) Option {
+	return func(s *Setting) {
+		s.AllowedValues = append(s.AllowedValues, values...)
+		s.Validators = append(s.Validators, Validator{
+			Rule: "enum=" + strings.Join(values, ","),
+			Func: func(value string) error {
+				if !slices.Contains(values, value) {
+					return fmt.Errorf("%q is not one of %s", value, strings.Join(values, ", "))
+				}
+				return nil
+			},
+		})
+	}
+}
+
+// RegisterEnumSetting is Registry.RegisterEnumSetting on the default registry.
+func RegisterEnumSetting(name, description string, prop *string, allowed []string, opts ...Option) {
+	defaultRegistry.RegisterEnumSetting(name, description, prop, allowed, opts...)
+}
+
+// RegisterEnumSetting registers a string setting that only accepts one of the allowed values.
+func (r *Registry) RegisterEnumSetting(name, description string, prop *string, allowed []string, opts ...Option) {
+	registerPointer(r, name, description, prop, StringCodec, append([]Option{OneOf(allowed...)}, opts...)...)
+}
+
+// RegisterTypedEnumSetting is RegisterEnumSetting for typed string constants:
+//
+//	type Level string
+//	const (Debug Level = "debug"; Info Level = "info")
+//	RegisterTypedEnumSetting("logging.level", "Log level", &level, []Level{Debug, Info})
+func RegisterTypedEnumSetting[T ~string](name, description string, prop *T, allowed []T, opts ...Option) {
+	RegisterTypedEnumSettingIn(defaultRegistry, name, description, prop, allowed, opts...)
+}
+
+// RegisterTypedEnumSettingIn is RegisterTypedEnumSetting for a specific registry.
+func RegisterTypedEnumSettingIn[T ~string](r *Registry, name, description string, prop *T, allowed []T, opts ...Option) {
+	values := make([]string, 0, len(allowed))
+	for _, v := range allowed {
+		values = append(values, string(v))
+	}
+	codec := NewCodec(
+		func(s string) (T, error) { return T(s), nil },
+		func(v T) string { return string(v) },
+	)
+	registerPointer(r, name, description, prop, codec, append([]Option{OneOf(values...)}, opts...)...)
+}
+
+// describe returns the description shown by the settings CLI, including the allowed values of enum settings.
+func (s *Setting) describe() string {
+	if len(s.AllowedValues) == 0 {
+		return s.Description
+	}
+	allowed := "one of: " + strings.Join(s.AllowedValues, ", ")
+	if s.Description == "" {
+		return allowed
+	}
+	return s.Description + " (" + allowed + ")"
+}
+
+// Help lists the allowed values of enum settings in `settings --help`.
+func (c *SettingsCommand) Help() string {
+	r, _ := c.ProvideRegistry()
+	lines := []string{}
+	for _, s := range r.snapshot() {
+		if s.Hidden || len(s.AllowedValues) == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", s.Name, strings.Join(s.AllowedValues, ", ")))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	slices.Sort(lines)
+	return "Allowed values:\n" + strings.Join(lines, "\n")
+}
+
+// Run prints completion candidates for the words following `settings`, one per line. Shell completion
+// scripts call it as `myapp settings complete save <partial>`.
+func (c *SettingsCompleteCommand) Run(r *Registry) error {
+	args := c.Args
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	for _, candidate := range r.Complete(args) {
+		fmt.Println(candidate)
+	}
+	return nil
+}
+
+// Complete returns completion candidates for a partial `settings` command line: setting names after
+// save, set, remove, unset, reset, get, history and rollback, and the allowed values of enum settings after `save <name>`.
+func (r *Registry) Complete(args []string) []string {
+	if len(args) < 2 {
+		return nil
+	}
+	candidates := []string{}
+	switch cmd, word := args[0], args[len(args)-1]; {
+	case len(args) == 2 && slices.Contains([]string{"save", "set", "remove", "unset", "reset", "get", "history", "rollback"}, cmd):
+		for _, s := range r.snapshot() {
+			if !s.Hidden && strings.HasPrefix(s.Name, word) {
+				candidates = append(candidates, s.Name)
+			}
+		}
+	case len(args) == 3 && (cmd == "save" || cmd == "set"):
+		setting, err := r.getCLISetting(args[1])
+		if err != nil {
+			return nil
+		}
+		for _, v := range setting.AllowedValues {
+			if strings.HasPrefix(v, word) {
+				candidates = append(candidates, v)
+			}
+		}
+	}
+	slices.Sort(candidates)
+	return candidates
+}
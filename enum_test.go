@@ -0,0 +1,76 @@
+package app_settings
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+)
+
+type testLevel string
+
+const (
+	testLevelDebug testLevel = "debug"
+	testLevelInfo  testLevel = "info"
+)
+
+func TestEnumSettings_RejectAndSurfaceAllowedValues(t *testing.T) {
+	r := NewRegistry()
+	format := "json"
+	level := testLevelInfo
+	r.RegisterEnumSetting("log.format", "Log format", &format, []string{"json", "text"})
+	RegisterTypedEnumSettingIn(r, "log.level", "Log level", &level, []testLevel{testLevelDebug, testLevelInfo})
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	var verr *ValidationError
+	if err := r.SetSetting("log.format", "xml"); !errors.As(err, &verr) || verr.Rule != "enum=json,text" {
+		t.Fatalf("expected enum rejection, got %v", err)
+	}
+	if err := r.SetSetting("log.level", testLevelDebug); err != nil {
+		t.Fatalf("SetSetting with typed constant failed: %v", err)
+	}
+	if level != testLevelDebug {
+		t.Fatalf("expected level to be debug, got %q", level)
+	}
+
+	out := captureStdout(func() {
+		_ = (&SettingsListActiveCommand{}).Run(r)
+	})
+	if !strings.Contains(out, "one of: json, text") {
+		t.Fatalf("list output does not show allowed values: %s", out)
+	}
+
+	var cli struct {
+		Settings SettingsCommand `cmd:""`
+	}
+	cli.Settings.Registry = r
+	var help strings.Builder
+	parser, err := kong.New(&cli, kong.Writers(&help, &help), kong.Exit(func(int) {}))
+	if err != nil {
+		t.Fatalf("kong.New failed: %v", err)
+	}
+	_, _ = parser.Parse([]string{"settings", "--help"})
+	if !strings.Contains(help.String(), "log.level: debug, info") {
+		t.Fatalf("help output does not show allowed values: %s", help.String())
+	}
+
+	ctx, err := parser.Parse([]string{"settings", "complete", "save", "log.level", "d"})
+	if err != nil {
+		t.Fatalf("parse complete failed: %v", err)
+	}
+	out = captureStdout(func() {
+		if err := ctx.Run(); err != nil {
+			t.Errorf("complete failed: %v", err)
+		}
+	})
+	if strings.TrimSpace(out) != "debug" {
+		t.Fatalf("unexpected value completion: %q", out)
+	}
+	if got := r.Complete([]string{"save", "log."}); !slices.Equal(got, []string{"log.format", "log.level"}) {
+		t.Fatalf("unexpected name completion: %v", got)
+	}
+}
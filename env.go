@@ -0,0 +1,135 @@
+package app_settings
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// EnvOptions enables an environment variable layer that overrides saved values. A setting whose variable
+// is set takes its value from the environment and refuses to be saved until the variable is removed.
+type EnvOptions struct {
+	// Prefix is prepended to every variable name, separator included, e.g. "MYAPP_".
+	Prefix string
+	// NameFunc maps a setting name to its variable name without the prefix. The default upper-cases the
+	// name and replaces every character that is not a letter or digit with an underscore, so "http.port"
+	// becomes HTTP_PORT.
+	NameFunc func(name string) string
+	// Files lists dotenv files whose variables join the layer. Variables of the process environment take
+	// precedence over file entries and later files over earlier ones. Missing files are ignored.
+	Files []string
+}
+
+// VariableName returns the environment variable that overrides the named setting.
+func (o *EnvOptions) VariableName(name string) string {
+	if o.NameFunc != nil {
+		return o.Prefix + o.NameFunc(name)
+	}
+	return o.Prefix + strings.Map(func(c rune) rune {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			return unicode.ToUpper(c)
+		}
+		return '_'
+	}, name)
+}
+
+// lookup returns the value of variable, consulting the process environment before the dotenv entries.
+func (o *EnvOptions) lookup(variable string, files map[string]string) (string, bool) {
+	if value, ok := os.LookupEnv(variable); ok {
+		return value, true
+	}
+	value, ok := files[variable]
+	return value, ok
+}
+
+// loadFiles reads the configured dotenv files into a single map.
+func (o *EnvOptions) loadFiles() (map[string]string, error) {
+	values := map[string]string{}
+	for _, name := range o.Files {
+		entries, err := readDotenv(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range entries {
+			values[k] = v
+		}
+	}
+	return values, nil
+}
+
+// readDotenv parses a dotenv file: KEY=VALUE lines, optionally preceded by "export", with blank lines and
+// lines starting with # ignored. Values may be wrapped in single or double quotes.
+func readDotenv(name string) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", name, n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	return values, nil
+}
+
+// envValues reads the environment layer and returns the value of every setting it overrides.
+func (r *Registry) envValues() (map[string]layerValue, error) {
+	r.mu.RLock()
+	env := r.env
+	r.mu.RUnlock()
+	values := map[string]layerValue{}
+	if env == nil {
+		return values, nil
+	}
+	files, err := env.loadFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range r.snapshot() {
+		variable := env.VariableName(s.Name)
+		if value, ok := env.lookup(variable, files); ok {
+			values[s.Name] = layerValue{value: value, source: SourceEnv, origin: variable}
+		}
+	}
+	return values, nil
+}
+
+// envOverride returns the environment variable overriding the named setting, if any.
+func (r *Registry) envOverride(name string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	variable, ok := r.envOverrides[name]
+	return variable, ok
+}
+
+// checkNotOverridden refuses changes to a setting whose value is pinned by the environment, since a saved
+// value would be shadowed by the variable on every reload.
+func (r *Registry) checkNotOverridden(setting *Setting) error {
+	if variable, ok := r.envOverride(setting.Name); ok {
+		return fmt.Errorf("setting %s is overridden by environment variable %s; change or unset the variable instead", setting.Name, variable)
+	}
+	return nil
+}
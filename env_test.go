@@ -0,0 +1,135 @@
+package app_settings
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvLayer_OverridesSavedValuesAndRefusesSave(t *testing.T) {
+	path := tempDBPath(t)
+	port := 8080
+	r := NewRegistry()
+	r.RegisterIntSetting("http.port", "HTTP port", &port)
+	if err := r.Setup(path, SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.SetSetting("http.port", 8081); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+
+	t.Setenv("MYAPP_HTTP_PORT", "9000")
+	env := &EnvOptions{Prefix: "MYAPP_"}
+	port = 8080
+	r = NewRegistry()
+	r.RegisterIntSetting("http.port", "HTTP port", &port)
+	if err := r.Setup(path, SettingsOptions{Env: env}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if port != 9000 {
+		t.Fatalf("expected env to win over the saved value, got %d", port)
+	}
+
+	var err error
+	out := captureStdout(func() {
+		err = (&SettingsSaveCommand{Setting: "http.port", Value: "7000"}).Run(r)
+	})
+	if err == nil || !strings.Contains(out, "MYAPP_HTTP_PORT") {
+		t.Fatalf("expected save to be refused naming the variable, got %v: %s", err, out)
+	}
+	if err := r.SetSetting("http.port", 7000); err == nil {
+		t.Fatalf("expected SetSetting to be refused")
+	}
+	if port != 9000 {
+		t.Fatalf("refused save changed the value to %d", port)
+	}
+
+	os.Unsetenv("MYAPP_HTTP_PORT")
+	if err := r.RetrieveAppSettings(); err != nil {
+		t.Fatalf("RetrieveAppSettings failed: %v", err)
+	}
+	if port != 8081 {
+		t.Fatalf("expected saved value once the variable is gone, got %d", port)
+	}
+	if err := r.SetSetting("http.port", 7000); err != nil {
+		t.Fatalf("SetSetting after unsetting the variable failed: %v", err)
+	}
+}
+
+func TestEnvLayer_DotenvFilesAndNameFunc(t *testing.T) {
+	dir := t.TempDir()
+	dotenv := filepath.Join(dir, ".env")
+	content := "# local overrides\nexport APP_LOG_LEVEL=\"debug\"\nAPP_REGION='eu-west'\n\nAPP_PORT=abc\n"
+	if err := os.WriteFile(dotenv, []byte(content), 0o600); err != nil {
+		t.Fatalf("write .env failed: %v", err)
+	}
+	t.Setenv("APP_REGION", "us-east")
+
+	level, region := "info", "eu"
+	r := NewRegistry()
+	r.RegisterEnumSetting("log.level", "Log level", &level, []string{"debug", "info"})
+	r.RegisterStringSetting("region", "Region", &region)
+	env := &EnvOptions{Prefix: "APP_", Files: []string{dotenv, filepath.Join(dir, "missing.env")}}
+	if err := r.Setup(tempDBPath(t), SettingsOptions{Env: env}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if level != "debug" || region != "us-east" {
+		t.Fatalf("unexpected values level=%q region=%q", level, region)
+	}
+
+	port := 1
+	r = NewRegistry()
+	r.RegisterIntSetting("port", "Port", &port)
+	err := r.Setup(tempDBPath(t), SettingsOptions{Env: env})
+	if err == nil || !strings.Contains(err.Error(), "APP_PORT") {
+		t.Fatalf("expected invalid env value error naming the variable, got %v", err)
+	}
+
+	custom := &EnvOptions{Prefix: "X_", NameFunc: func(name string) string { return strings.ReplaceAll(name, ".", "__") }}
+	if got := custom.VariableName("log.level"); got != "X_log__level" {
+		t.Fatalf("unexpected custom variable name %q", got)
+	}
+	if got := (&EnvOptions{}).VariableName("db.pool-size"); got != "DB_POOL_SIZE" {
+		t.Fatalf("unexpected default variable name %q", got)
+	}
+}
+
+func TestEnvLayer_RefusesImportAndRollback(t *testing.T) {
+	path := tempDBPath(t)
+	port := 8080
+	r := NewRegistry()
+	r.RegisterIntSetting("http.port", "HTTP port", &port)
+	if err := r.Setup(path, SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	for _, v := range []int{8081, 8082} {
+		if err := r.SetSetting("http.port", v); err != nil {
+			t.Fatalf("SetSetting failed: %v", err)
+		}
+	}
+
+	t.Setenv("MYAPP_HTTP_PORT", "9000")
+	r = NewRegistry()
+	r.RegisterIntSetting("http.port", "HTTP port", &port)
+	if err := r.Setup(path, SettingsOptions{Env: &EnvOptions{Prefix: "MYAPP_"}}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	file := writeFile(t, filepath.Join(t.TempDir(), "settings.json"), `{"http.port": "7000"}`)
+	if _, err := r.Import(file, ImportOptions{DryRun: true}); err == nil || !strings.Contains(err.Error(), "MYAPP_HTTP_PORT") {
+		t.Fatalf("expected the dry run to be refused naming the variable, got %v", err)
+	}
+	if _, err := r.Import(file, ImportOptions{}); err == nil {
+		t.Fatalf("expected Import to be refused")
+	}
+	if err := r.Rollback("http.port"); err == nil || !strings.Contains(err.Error(), "MYAPP_HTTP_PORT") {
+		t.Fatalf("expected Rollback to be refused naming the variable, got %v", err)
+	}
+	saved, err := r.savedStates(r.store)
+	if err != nil {
+		t.Fatalf("savedStates failed: %v", err)
+	}
+	if saved["http.port"].value != "8082" || port != 9000 {
+		t.Fatalf("refused changes touched the setting: saved %q, running %d", saved["http.port"].value, port)
+	}
+}
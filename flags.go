@@ -0,0 +1,132 @@
+package app_settings
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/alecthomas/kong"
+)
+
+// SettingsFlags is a Kong mixin that overrides settings for a single run without saving them. Embed it in
+// the application's CLI struct:
+//
+//	var CLI struct {
+//		app_settings.SettingsDef
+//		app_settings.SettingsFlags `embed:""`
+//	}
+//
+// to accept a repeatable --set name=value flag. Call AddSettingFlags before building the parser to also get
+// one --<name> flag per visible setting.
+type SettingsFlags struct {
+	// Registry selects the registry the flags apply to; nil means the default registry.
+	Registry *Registry `kong:"-"`
+
+	Set []string `help:"Override a setting for this run without saving it (repeatable)" placeholder:"NAME=VALUE" sep:"none" group:"App Settings"`
+
+	// Plugins carries the per-setting flags added by AddSettingFlags.
+	kong.Plugins
+
+	settingFlags reflect.Value
+	settingNames []string
+}
+
+func (f *SettingsFlags) registry() *Registry {
+	if f.Registry != nil {
+		return f.Registry
+	}
+	return defaultRegistry
+}
+
+// AddSettingFlags adds a --<name> flag for every visible setting registered so far. Settings whose name
+// collides with another flag of the application make kong.New fail, so hide them or use --set instead.
+func (f *SettingsFlags) AddSettingFlags() {
+	fields := []reflect.StructField{}
+	names := []string{}
+	for _, s := range f.registry().snapshot() {
+		if s.Hidden {
+			continue
+		}
+		help := strings.ReplaceAll(s.describe(), "$", "$$")
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Setting%d", len(fields)),
+			Type: reflect.TypeFor[*string](),
+			Tag:  reflect.StructTag(fmt.Sprintf(`name:%q help:%q placeholder:"VALUE" group:"Settings"`, s.Name, help)),
+		})
+		names = append(names, s.Name)
+	}
+	if len(fields) == 0 {
+		return
+	}
+	f.settingFlags = reflect.New(reflect.StructOf(fields))
+	f.settingNames = names
+	f.Plugins = append(f.Plugins, f.settingFlags.Interface())
+}
+
+// AfterApply applies the overrides given on the command line once Kong has parsed it.
+func (f *SettingsFlags) AfterApply() error {
+	values := map[string]string{}
+	for _, arg := range f.Set {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("--set %s: expected NAME=VALUE", arg)
+		}
+		values[name] = value
+	}
+	if f.settingFlags.IsValid() {
+		for i, name := range f.settingNames {
+			if p := f.settingFlags.Elem().Field(i).Interface().(*string); p != nil {
+				values[name] = *p
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return f.registry().ApplyOverrides(values)
+}
+
+// ApplyOverrides applies overrides to the default registry. See Registry.ApplyOverrides.
+func ApplyOverrides(values map[string]string) error {
+	return defaultRegistry.ApplyOverrides(values)
+}
+
+// ApplyOverrides sets the named settings for the life of the process without saving them. The values form
+// the top layer: they survive reloads by RetrieveAppSettings and are reported with SourceFlag. Every value is
+// validated before any is applied.
+func (r *Registry) ApplyOverrides(values map[string]string) error {
+	settings := make(map[string]*Setting, len(values))
+	for name, value := range values {
+		setting, err := r.GetSetting(name)
+		if err != nil {
+			return err
+		}
+		if err := setting.Validate(value); err != nil {
+			return setting.maskError(err)
+		}
+		settings[name] = setting
+	}
+	r.mu.Lock()
+	if r.flagValues == nil {
+		r.flagValues = map[string]string{}
+	}
+	for name, value := range values {
+		r.flagValues[name] = value
+	}
+	r.mu.Unlock()
+	for name, value := range values {
+		if err := r.apply(context.Background(), settings[name], value, SourceFlag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flagValue returns the command line override of the named setting, if any.
+func (r *Registry) flagValue(name string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	value, ok := r.flagValues[name]
+	return value, ok
+}
@@ -0,0 +1,69 @@
+package app_settings
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+)
+
+func TestSettingsFlags_OverrideForOneRun(t *testing.T) {
+	r := NewRegistry()
+	port, region := 8080, "eu"
+	level := "info"
+	r.RegisterIntSetting("http.port", "HTTP port", &port)
+	r.RegisterStringSetting("region", "Region", &region)
+	r.RegisterEnumSetting("log.level", "Log level", &level, []string{"debug", "info"})
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	var cli struct {
+		SettingsFlags `embed:""`
+		Settings      SettingsCommand `cmd:""`
+	}
+	cli.SettingsFlags.Registry = r
+	cli.Settings.Registry = r
+	cli.AddSettingFlags()
+	parser, err := kong.New(&cli, kong.Exit(func(int) {}))
+	if err != nil {
+		t.Fatalf("kong.New failed: %v", err)
+	}
+	ctx, err := parser.Parse([]string{"--set", "region=us=west", "--http.port", "9000", "settings", "list", "active"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if port != 9000 || region != "us=west" {
+		t.Fatalf("flags not applied: port=%d region=%q", port, region)
+	}
+	out := captureStdout(func() {
+		if err := ctx.Run(); err != nil {
+			t.Errorf("list active failed: %v", err)
+		}
+	})
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, "http.port") && !strings.Contains(line, string(SourceFlag)) {
+			t.Fatalf("expected flag source for http.port: %s", out)
+		}
+	}
+
+	if rows, _ := r.store.AppSetting.Find(); len(rows) != 0 {
+		t.Fatalf("flag values must not be persisted: %#v", rows)
+	}
+	if err := r.RetrieveAppSettings(); err != nil {
+		t.Fatalf("RetrieveAppSettings failed: %v", err)
+	}
+	if port != 9000 {
+		t.Fatalf("flag value should stay on top after a reload, got %d", port)
+	}
+
+	if _, err := parser.Parse([]string{"--log.level", "trace", "settings", "list", "active"}); err == nil || !strings.Contains(err.Error(), "enum=debug,info") {
+		t.Fatalf("expected validation error for flag value, got %v", err)
+	}
+	if _, err := parser.Parse([]string{"--set", "region", "settings", "list", "active"}); err == nil {
+		t.Fatalf("expected error for --set without a value")
+	}
+	if level != "info" {
+		t.Fatalf("rejected flag changed the value to %q", level)
+	}
+}
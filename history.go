@@ -0,0 +1,210 @@
+package app_settings
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/dan-sherwin/go-app-settings/db"
+	"github.com/dan-sherwin/go-app-settings/db/models"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Operations recorded in the history table.
+const (
+	OpSave     = "save"
+	OpDelete   = "delete"
+	OpRollback = "rollback"
+	OpImport   = "import"
+)
+
+// HistoryRetention bounds the history table. Entries older than MaxAge or beyond the newest MaxRows are
+// deleted whenever a change is recorded; zero values keep everything.
+type HistoryRetention struct {
+	MaxAge  time.Duration
+	MaxRows int
+}
+
+// recordHistory adds a history entry inside tx, applies the retention policy and returns the ID of the
+// entry. Values are passed in their stored form so encrypted settings stay encrypted in the history;
+// oldSaved and newSaved tell a saved empty value apart from no saved value.
+func (r *Registry) recordHistory(tx *db.Store, name, op, oldValue, newValue string, oldSaved, newSaved bool, reason string) (int64, error) {
+	changedBy, host := changeIdentity()
+	entry := &models.AppSettingHistory{
+		Key:       name,
+		OldValue:  oldValue,
+		NewValue:  newValue,
+		Operation: op,
+		ChangedAt: time.Now(),
+		User:      changedBy,
+		Hostname:  host,
+		Reason:    reason,
+		OldSaved:  &oldSaved,
+		NewSaved:  &newSaved,
+	}
+	if err := tx.AppSettingHistory.Create(entry); err != nil {
+		return 0, fmt.Errorf("record history of setting %s: %w", name, err)
+	}
+	r.mu.RLock()
+	retention := r.retention
+	r.mu.RUnlock()
+	h := tx.AppSettingHistory
+	if retention.MaxAge > 0 {
+		if _, err := h.Where(h.ChangedAt.Lt(time.Now().Add(-retention.MaxAge))).Delete(); err != nil {
+			return 0, fmt.Errorf("prune history: %w", err)
+		}
+	}
+	if retention.MaxRows > 0 {
+		oldest, err := h.Order(h.ID.Desc()).Offset(retention.MaxRows).Limit(1).Find()
+		if err != nil {
+			return 0, fmt.Errorf("prune history: %w", err)
+		}
+		if len(oldest) > 0 {
+			if _, err := h.Where(h.ID.Lte(oldest[0].ID)).Delete(); err != nil {
+				return 0, fmt.Errorf("prune history: %w", err)
+			}
+		}
+	}
+	return entry.ID, nil
+}
+
+// changeIdentity returns the OS user and hostname recorded with a change.
+func changeIdentity() (string, string) {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, _ := os.Hostname()
+	return name, host
+}
+
+// History returns the history of the default registry. See Registry.History.
+func History(name string, since time.Time) ([]*models.AppSettingHistory, error) {
+	return defaultRegistry.History(name, since)
+}
+
+// HistoryContext returns the history of the default registry. See Registry.HistoryContext.
+func HistoryContext(ctx context.Context, name string, since time.Time) ([]*models.AppSettingHistory, error) {
+	return defaultRegistry.HistoryContext(ctx, name, since)
+}
+
+// History returns the recorded changes, oldest first, with values decrypted. An empty name returns the
+// changes of every setting and a zero since returns the whole history.
+func (r *Registry) History(name string, since time.Time) ([]*models.AppSettingHistory, error) {
+	return r.HistoryContext(context.Background(), name, since)
+}
+
+// HistoryContext is History with a context bounding the database query.
+func (r *Registry) HistoryContext(ctx context.Context, name string, since time.Time) ([]*models.AppSettingHistory, error) {
+	store, err := r.storeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	h := store.AppSettingHistory
+	q := h.Order(h.ID)
+	if name != "" {
+		// Scoped overrides are recorded under name@scope.
+		q = q.Where(h.Where(h.Key.Eq(name)).Or(h.Key.Like(name + "@%")))
+	}
+	if !since.IsZero() {
+		q = q.Where(h.ChangedAt.Gte(since))
+	}
+	entries, err := q.Find()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting settings history: %w", err)
+	}
+	r.mu.RLock()
+	c := r.cipher
+	r.mu.RUnlock()
+	matching := entries[:0]
+	for _, e := range entries {
+		setting, ok := r.historySetting(e.Key)
+		if name != "" && e.Key != name && (!ok || setting.Name != name) {
+			continue
+		}
+		if ok {
+			for _, v := range []*string{&e.OldValue, &e.NewValue} {
+				if *v, err = decodeValue(c, e.Key, *v); err != nil {
+					return nil, err
+				}
+			}
+		}
+		matching = append(matching, e)
+	}
+	return matching, nil
+}
+
+// historySetting returns the setting a history entry belongs to: the setting named key, or for a scoped
+// override recorded under name@scope, the setting name.
+func (r *Registry) historySetting(key string) (*Setting, bool) {
+	if setting, err := r.GetSetting(key); err == nil {
+		return setting, true
+	}
+	for i := range len(key) {
+		if key[i] != '@' || validateScope(key[i+1:]) != nil {
+			continue
+		}
+		if setting, err := r.GetSetting(key[:i]); err == nil {
+			return setting, true
+		}
+	}
+	return nil, false
+}
+
+// parseTimeFlag parses the value of a time flag: a duration before now ("24h") or a time in RFC 3339,
+// "2006-01-02 15:04:05" or 2006-01-02 form. An empty value is the zero time.
+func parseTimeFlag(flag, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --%s %q: use a duration such as 24h or a time such as 2006-01-02", flag, value)
+}
+
+// Run prints the change history of one setting or of every visible setting.
+func (c *SettingsHistoryCommand) Run(r *Registry) error {
+	if c.Setting != "" {
+		if _, err := r.getCLISetting(c.Setting); err != nil {
+			return printAndReturnErr(err)
+		}
+	}
+	since, err := parseTimeFlag("since", c.Since)
+	if err != nil {
+		return printAndReturnErr(err)
+	}
+	entries, err := r.History(c.Setting, since)
+	if err != nil {
+		return printAndReturnErr(err)
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header([]string{"Version", "Time", "Setting", "Operation", "Old Value", "New Value", "User", "Host", "Reason"})
+	for _, e := range entries {
+		setting, ok := r.historySetting(e.Key)
+		if !ok || setting.Hidden {
+			continue
+		}
+		table.Append([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.ChangedAt.Local().Format(time.DateTime),
+			e.Key,
+			e.Operation,
+			setting.display(e.OldValue),
+			setting.display(e.NewValue),
+			e.User,
+			e.Hostname,
+			e.Reason,
+		})
+	}
+	table.Render()
+	return nil
+}
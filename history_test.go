@@ -0,0 +1,120 @@
+package app_settings
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestHistory_RecordsEveryWrite(t *testing.T) {
+	gormDB, err := gorm.Open(sqlite.Open(tempDBPath(t)), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db failed: %v", err)
+	}
+	r := NewRegistry()
+	region, password := "eu", ""
+	r.RegisterStringSetting("region", "Region", &region)
+	r.RegisterSecretSetting("db.password", "Password", &password, Encrypted())
+	options := SettingsOptions{TableName: "service_settings", Encryption: &EncryptionOptions{Key: StaticKey(bytes.Repeat([]byte{7}, 32))}}
+	if err := r.SetupWithDB(gormDB, options); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if !gormDB.Migrator().HasTable("service_settings_history") {
+		t.Fatalf("expected history table named after the settings table")
+	}
+
+	if err := r.SetSetting("region", "us"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	captureStdout(func() {
+		if err := (&SettingsSaveCommand{Setting: "region", Value: "ap", Reason: "latency test"}).Run(r); err != nil {
+			t.Errorf("save failed: %v", err)
+		}
+		if err := (&SettingsRemoveCommand{Setting: "region", Reason: "done"}).Run(r); err != nil {
+			t.Errorf("remove failed: %v", err)
+		}
+	})
+	if err := r.SetSetting("db.password", "hunter2"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+
+	entries, err := r.History("region", time.Time{})
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	want := [][3]string{{OpSave, "", "us"}, {OpSave, "us", "ap"}, {OpDelete, "ap", ""}}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %#v", len(want), entries)
+	}
+	for i, w := range want {
+		if e := entries[i]; e.Operation != w[0] || e.OldValue != w[1] || e.NewValue != w[2] || e.User == "" {
+			t.Fatalf("entry %d: unexpected %#v", i, e)
+		}
+	}
+	if entries[1].Reason != "latency test" {
+		t.Fatalf("reason not recorded: %#v", entries[1])
+	}
+
+	var raw string
+	if err := gormDB.Table("service_settings_history").Select("new_value").Where("key = ?", "db.password").Scan(&raw).Error; err != nil {
+		t.Fatalf("read raw history failed: %v", err)
+	}
+	if !strings.HasPrefix(raw, encryptedPrefix) {
+		t.Fatalf("encrypted setting stored in clear text in history: %q", raw)
+	}
+
+	out := captureStdout(func() {
+		if err := (&SettingsHistoryCommand{Since: "1h"}).Run(r); err != nil {
+			t.Errorf("history failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "latency test") || strings.Contains(out, "hunter2") || !strings.Contains(out, maskedValue) {
+		t.Fatalf("unexpected history output: %s", out)
+	}
+	out = captureStdout(func() {
+		_ = (&SettingsHistoryCommand{Since: time.Now().Add(time.Hour).Format(time.RFC3339)}).Run(r)
+	})
+	if strings.Contains(out, "region") {
+		t.Fatalf("--since did not filter entries: %s", out)
+	}
+	if err := (&SettingsHistoryCommand{Since: "last week"}).Run(r); err == nil {
+		t.Fatalf("expected invalid --since error")
+	}
+}
+
+func TestHistory_Retention(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var region string
+	r.RegisterStringSetting("region", "Region", &region)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{HistoryRetention: HistoryRetention{MaxRows: 2}}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	for _, v := range []string{"a", "b", "c", "d"} {
+		if err := r.SetSetting("region", v); err != nil {
+			t.Fatalf("SetSetting failed: %v", err)
+		}
+	}
+	entries, err := r.History("", time.Time{})
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].NewValue != "c" || entries[1].NewValue != "d" {
+		t.Fatalf("expected the newest two entries, got %#v", entries)
+	}
+
+	r.mu.Lock()
+	r.retention = HistoryRetention{MaxAge: time.Nanosecond}
+	r.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	if err := r.SetSetting("region", "e"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if entries, _ := r.History("", time.Time{}); len(entries) > 1 {
+		t.Fatalf("expected old entries to expire, got %d", len(entries))
+	}
+}
@@ -0,0 +1,108 @@
+package app_settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrHookPanic is matched by the error returned when a validator, SetFunc or change callback panics.
+var ErrHookPanic = errors.New("setting hook panicked")
+
+// ErrHookTimeout is matched by the error returned when a SetFunc or change callback runs longer than
+// SettingsOptions.HookTimeout.
+var ErrHookTimeout = errors.New("setting hook timed out")
+
+// recoverHook turns a panic in a user callback into an error stored in *err. Use it deferred.
+func recoverHook(err *error, setting, hook string) {
+	if v := recover(); v != nil {
+		*err = fmt.Errorf("%w: %s of setting %s: %v", ErrHookPanic, hook, setting, v)
+	}
+}
+
+// Apply runs the setting's SetFunc with value, returning a panic in it as an error matching ErrHookPanic.
+// It does not validate: call Validate first, as the registry does.
+func (s *Setting) Apply(value string) (err error) {
+	defer recoverHook(&err, s.Name, "SetFunc")
+	return s.SetFunc(value)
+}
+
+// abandonedError is the error of a hook given up on while it was running. The hook may still change the
+// setting when it returns, so nothing it did may be undone.
+type abandonedError struct{ error }
+
+func (e abandonedError) Unwrap() error { return e.error }
+
+// hookAbandoned reports whether err holds the error of a hook given up on while it was running.
+func hookAbandoned(err error) bool {
+	var abandoned abandonedError
+	return errors.As(err, &abandoned)
+}
+
+// runHook runs a user callback of the named setting, giving up after SettingsOptions.HookTimeout or when ctx
+// is done. A callback given up on keeps running in the background; its eventual result is discarded.
+func (r *Registry) runHook(ctx context.Context, setting, hook string, fn func() error) error {
+	return r.runHookIn(ctx, setting, hook, nil, fn)
+}
+
+// runSetFunc runs fn, a call of the setting's SetFunc, like runHook but one at a time per setting: a SetFunc
+// given up on holds the setting until it returns, and the next one waits for it within its own timeout
+// instead of running alongside it.
+func (r *Registry) runSetFunc(ctx context.Context, setting *Setting, fn func() error) error {
+	r.mu.Lock()
+	if r.setFuncSlots == nil {
+		r.setFuncSlots = map[string]chan struct{}{}
+	}
+	slot, ok := r.setFuncSlots[setting.Name]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		r.setFuncSlots[setting.Name] = slot
+	}
+	r.mu.Unlock()
+	return r.runHookIn(ctx, setting.Name, "SetFunc", slot, fn)
+}
+
+// runHookIn is runHook holding slot, when it is not nil, from before fn starts until it returns.
+func (r *Registry) runHookIn(ctx context.Context, setting, hook string, slot chan struct{}, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s of setting %s not run: %w", hook, setting, err)
+	}
+	r.mu.RLock()
+	timeout := r.hookTimeout
+	r.mu.RUnlock()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	release := func() {}
+	if slot != nil {
+		select {
+		case slot <- struct{}{}:
+			release = func() { <-slot }
+		case <-expired:
+			return fmt.Errorf("%w: %s of setting %s not run: the previous one is still running after %s", ErrHookTimeout, hook, setting, timeout)
+		case <-ctx.Done():
+			return fmt.Errorf("%s of setting %s not run: %w", hook, setting, ctx.Err())
+		}
+	}
+	if expired == nil && ctx.Done() == nil {
+		defer release()
+		return fn()
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer release()
+		done <- fn()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-expired:
+		return abandonedError{fmt.Errorf("%w: %s of setting %s did not return within %s", ErrHookTimeout, hook, setting, timeout)}
+	case <-ctx.Done():
+		return abandonedError{fmt.Errorf("%s of setting %s abandoned: %w", hook, setting, ctx.Err())}
+	}
+}
@@ -0,0 +1,174 @@
+package app_settings
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSetSetting_WriteFailureKeepsRunningValue(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	region := "eu"
+	applied := 0
+	r.RegisterSetting(&Setting{
+		Name:    "region",
+		GetFunc: func() string { return region },
+		SetFunc: func(s string) error {
+			applied++
+			region = s
+			return nil
+		},
+	})
+	gormDB, err := gorm.Open(sqlite.Open(tempDBPath(t)), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db failed: %v", err)
+	}
+	if err := r.SetupWithDB(gormDB, SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := gormDB.Exec("CREATE TRIGGER fail_insert BEFORE INSERT ON app_settings BEGIN SELECT RAISE(ABORT, 'disk full'); END").Error; err != nil {
+		t.Fatalf("create trigger failed: %v", err)
+	}
+	applied = 0
+
+	if err := r.SetSetting("region", "us"); err == nil {
+		t.Fatalf("expected the failed write to fail SetSetting")
+	}
+	if region != "eu" || applied != 0 {
+		t.Fatalf("value applied although it was never saved: region=%q applied=%d", region, applied)
+	}
+}
+
+func TestSetSetting_PanickingHooks(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	mode := "fast"
+	r.RegisterSetting(&Setting{
+		Name:    "mode",
+		GetFunc: func() string { return mode },
+		SetFunc: func(s string) error {
+			if s == "boom" {
+				panic("cannot apply boom")
+			}
+			mode = s
+			return nil
+		},
+		Validators: []Validator{{Rule: "sane", Func: func(s string) error {
+			if s == "crash" {
+				panic("validator crashed")
+			}
+			return nil
+		}}},
+	})
+	var mu sync.Mutex
+	var reported []error
+	if err := r.Setup(tempDBPath(t), SettingsOptions{OnError: func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.SetSetting("mode", "slow"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+
+	if err := r.SetSetting("mode", "boom"); !errors.Is(err, ErrHookPanic) {
+		t.Fatalf("expected ErrHookPanic from SetFunc, got %v", err)
+	}
+	if err := r.SetSetting("mode", "crash"); !errors.Is(err, ErrHookPanic) {
+		t.Fatalf("expected ErrHookPanic from validator, got %v", err)
+	}
+	if saved, _ := r.savedStates(r.store); mode != "slow" || saved["mode"].value != "slow" {
+		t.Fatalf("panics changed the setting: running=%q saved=%q", mode, saved["mode"].value)
+	}
+
+	r.OnChange("mode", func(string, string) { panic("callback crashed") })
+	calls := 0
+	r.OnChange("mode", func(string, string) { calls++ })
+	if err := r.SetSetting("mode", "fast"); err != nil {
+		t.Fatalf("a panicking callback failed SetSetting: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if mode != "fast" || calls != 1 || len(reported) != 1 || !errors.Is(reported[0], ErrHookPanic) {
+		t.Fatalf("callback panic not contained: mode=%q calls=%d reported=%v", mode, calls, reported)
+	}
+}
+
+func TestSetSetting_HookTimeout(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var mu sync.Mutex
+	mode := "fast"
+	running, overlapped := 0, false
+	release := make(chan struct{})
+	r.RegisterSetting(&Setting{
+		Name: "mode",
+		GetFunc: func() string {
+			mu.Lock()
+			defer mu.Unlock()
+			return mode
+		},
+		SetFunc: func(s string) error {
+			mu.Lock()
+			running++
+			overlapped = overlapped || running > 1
+			mu.Unlock()
+			if s == "stuck" {
+				<-release
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			running--
+			mode = s
+			return nil
+		},
+	})
+	if err := r.Setup(tempDBPath(t), SettingsOptions{HookTimeout: 50 * time.Millisecond}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.SetSetting("mode", "slow"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+
+	start := time.Now()
+	if err := r.SetSetting("mode", "stuck"); !errors.Is(err, ErrHookTimeout) {
+		t.Fatalf("expected ErrHookTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("SetSetting waited %s for a stuck SetFunc", elapsed)
+	}
+	// The stuck SetFunc may still apply its value, so it stays saved rather than being reverted under it.
+	if saved, _ := r.savedStates(r.store); saved["mode"].value != "stuck" {
+		t.Fatalf("timed out value not left saved: %q", saved["mode"].value)
+	}
+
+	// The next SetFunc does not run alongside the stuck one; the change fails and is undone.
+	if err := r.SetSetting("mode", "fast"); !errors.Is(err, ErrHookTimeout) {
+		t.Fatalf("expected ErrHookTimeout while the previous SetFunc runs, got %v", err)
+	}
+	if saved, _ := r.savedStates(r.store); saved["mode"].value != "stuck" {
+		t.Fatalf("change that never ran left saved: %q", saved["mode"].value)
+	}
+
+	close(release)
+	waitFor(t, "the stuck SetFunc to finish", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return mode == "stuck"
+	})
+	if err := r.SetSetting("mode", "fast"); err != nil {
+		t.Fatalf("SetSetting after the stuck SetFunc returned failed: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if overlapped {
+		t.Fatalf("two SetFuncs of the setting ran at once")
+	}
+}
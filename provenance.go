@@ -0,0 +1,76 @@
+package app_settings
+
+// Source identifies where the running value of a setting came from.
+type Source string
+
+const (
+	// SourceDefault is the value the setting had in code when it was registered, or a reset to it.
+	SourceDefault Source = "default"
+	// SourceDB is a value saved in the settings table.
+	SourceDB Source = "db"
+	// SourceFile is a value read from one of SettingsOptions.ConfigFiles.
+	SourceFile Source = "file"
+	// SourceEnv is a value taken from an environment variable or dotenv file.
+	SourceEnv Source = "env"
+	// SourceFlag is a value given on the command line through SettingsFlags or set with ApplyOverrides.
+	SourceFlag Source = "flag"
+	// SourceRuntime is a value the application changed in memory without going through the registry.
+	SourceRuntime Source = "runtime"
+)
+
+// provenance is the origin of a setting's value together with the value it produced, so changes made
+// behind the registry's back can be detected.
+type provenance struct {
+	source Source
+	value  string
+}
+
+// layerValue is a value offered by one of the configuration layers, with the file or variable it came from.
+type layerValue struct {
+	value  string
+	source Source
+	origin string
+}
+
+// recordSource remembers that the named setting now holds value because of source.
+func (r *Registry) recordSource(name string, source Source, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sources == nil {
+		r.sources = map[string]provenance{}
+	}
+	r.sources[name] = provenance{source: source, value: value}
+}
+
+// ValueSource reports where the running value of a setting of the default registry came from.
+func ValueSource(name string) (Source, error) {
+	return defaultRegistry.ValueSource(name)
+}
+
+// ValueSource reports where the running value of the named setting came from. A value that no longer
+// matches the one the registry last applied was changed directly by the application and is reported as
+// SourceRuntime.
+func (r *Registry) ValueSource(name string) (Source, error) {
+	setting, err := r.GetSetting(name)
+	if err != nil {
+		return "", err
+	}
+	return r.source(setting), nil
+}
+
+func (r *Registry) source(setting *Setting) Source {
+	value := setting.GetFunc()
+	r.mu.RLock()
+	p, recorded := r.sources[setting.Name]
+	r.mu.RUnlock()
+	if !recorded {
+		if d, ok := r.defaultValue(setting.Name); ok && d != value {
+			return SourceRuntime
+		}
+		return SourceDefault
+	}
+	if p.value != value {
+		return SourceRuntime
+	}
+	return p.source
+}
@@ -0,0 +1,70 @@
+package app_settings
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dan-sherwin/go-app-settings/db/models"
+)
+
+func TestValueSource_TracksEveryOrigin(t *testing.T) {
+	path := tempDBPath(t)
+	r := NewRegistry()
+	var saved, fromEnv, untouched, poked string
+	r.RegisterStringSetting("saved", "Saved", &saved)
+	r.RegisterStringSetting("from.env", "From env", &fromEnv)
+	r.RegisterStringSetting("untouched", "Untouched", &untouched)
+	r.RegisterStringSetting("poked", "Poked", &poked)
+	t.Setenv("SRC_FROM_ENV", "env-value")
+	if err := r.Setup(path, SettingsOptions{Env: &EnvOptions{Prefix: "SRC_"}}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.SetSetting("saved", "db-value"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	poked = "changed in code"
+
+	want := map[string]Source{
+		"saved":     SourceDB,
+		"from.env":  SourceEnv,
+		"untouched": SourceDefault,
+		"poked":     SourceRuntime,
+	}
+	for name, source := range want {
+		if got, err := r.ValueSource(name); err != nil || got != source {
+			t.Fatalf("%s: expected source %q, got %q (%v)", name, source, got, err)
+		}
+	}
+	if _, err := r.ValueSource("missing"); err == nil {
+		t.Fatalf("expected error for unknown setting")
+	}
+
+	running := []models.AppSetting{}
+	if err := (&settingsService{registry: r}).GetRunningSettings(&struct{}{}, &running); err != nil {
+		t.Fatalf("GetRunningSettings failed: %v", err)
+	}
+	for _, s := range running {
+		if Source(s.Source) != want[s.Key] {
+			t.Fatalf("RPC reply reports source %q for %s, expected %q", s.Source, s.Key, want[s.Key])
+		}
+	}
+
+	out := captureStdout(func() {
+		_ = (&SettingsListActiveCommand{}).Run(r)
+	})
+	if !strings.Contains(strings.ToLower(out), "source") {
+		t.Fatalf("active list has no source column: %s", out)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, "from.env") && !strings.Contains(line, "env-value") || strings.Contains(line, "poked") && !strings.Contains(line, string(SourceRuntime)) {
+			t.Fatalf("unexpected active row: %s", line)
+		}
+	}
+
+	if err := r.ResetSetting("saved"); err != nil {
+		t.Fatalf("ResetSetting failed: %v", err)
+	}
+	if got, _ := r.ValueSource("saved"); got != SourceDefault {
+		t.Fatalf("expected reset setting to report the default, got %q", got)
+	}
+}
@@ -2,67 +2,79 @@ package app_settings
 
 import (
 	"encoding/json"
-	"fmt"
 	"net"
 	"net/url"
-	"strconv"
-	"strings"
 	"time"
-
-	"github.com/robfig/cron/v3"
 )
 
-// RegisterSettingReceiver registers a SettingReceiver by wrapping its methods in a Setting struct and appending it to the global settings list.
+// RegisterSettingReceiver registers a SettingReceiver with the default registry.
 func RegisterSettingReceiver(r SettingReceiver) {
-	RegisterSetting(&Setting{
-		SetFunc:     r.SettingSet,
-		GetFunc:     r.SettingGet,
-		Name:        r.SettingName(),
-		Description: r.SettingDescription(),
+	defaultRegistry.RegisterSettingReceiver(r)
+}
+
+// RegisterSettingReceiver registers a SettingReceiver by wrapping its methods in a Setting struct and appending it to the registry.
+func (r *Registry) RegisterSettingReceiver(recv SettingReceiver) {
+	r.RegisterSetting(&Setting{
+		SetFunc:     recv.SettingSet,
+		GetFunc:     recv.SettingGet,
+		Name:        recv.SettingName(),
+		Description: recv.SettingDescription(),
 	})
 }
 
-// RegisterSetting adds a given Setting to the global settings list.
+// RegisterSetting adds a given Setting to the default registry.
 func RegisterSetting(s *Setting) {
-	settingsMu.Lock()
-	defer settingsMu.Unlock()
-	settings = append(settings, s)
+	defaultRegistry.RegisterSetting(s)
+}
+
+// RegisterSetting adds a given Setting to the registry.
+func (r *Registry) RegisterSetting(s *Setting) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.settings = append(r.settings, s)
+}
+
+// RegisterStringSetting is Registry.RegisterStringSetting on the default registry.
+func RegisterStringSetting(name string, description string, prop *string, opts ...Option) {
+	defaultRegistry.RegisterStringSetting(name, description, prop, opts...)
 }
 
 // RegisterStringSetting registers a string setting with a specified name, description, and a pointer to the property to manage its value.
-func RegisterStringSetting(name string, description string, prop *string) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc: func() string {
-			return *prop
-		},
-		SetFunc: func(s string) error {
-			*prop = s
-			return nil
-		},
-	})
+func (r *Registry) RegisterStringSetting(name string, description string, prop *string, opts ...Option) {
+	registerPointer(r, name, description, prop, StringCodec, opts...)
+}
+
+func RegisterJSONSetting[T any](name, description string, prop *T, opts ...Option) {
+	RegisterJSONSettingIn(defaultRegistry, name, description, prop, opts...)
+}
+
+// RegisterJSONSettingIn is RegisterJSONSetting for a specific registry.
+func RegisterJSONSettingIn[T any](r *Registry, name, description string, prop *T, opts ...Option) {
+	RegisterJSONSettingWithValidatorIn(r, name, description, prop, nil, opts...)
 }
 
-func RegisterJSONSetting[T any](name, description string, prop *T) {
-	RegisterJSONSettingWithValidator(name, description, prop, nil)
+func RegisterJSONSettingWithValidator[T any](name, description string, prop *T, validate func(T) error, opts ...Option) {
+	RegisterJSONSettingWithValidatorIn(defaultRegistry, name, description, prop, validate, opts...)
 }
 
-func RegisterJSONSettingWithValidator[T any](name, description string, prop *T, validate func(T) error) {
-	RegisterSetting(&Setting{
+// RegisterJSONSettingWithValidatorIn is RegisterJSONSettingWithValidator for a specific registry.
+func RegisterJSONSettingWithValidatorIn[T any](r *Registry, name, description string, prop *T, validate func(T) error, opts ...Option) {
+	codec := JSONCodec[T]()
+	r.RegisterSetting(applyOptions(&Setting{
 		Name:              name,
 		Description:       description,
 		ValueToStringFunc: jsonValueToString,
-		GetFunc: func() string {
-			b, err := json.Marshal(*prop)
-			if err != nil {
-				return ""
+		ParseFunc: func(s string) error {
+			value, err := codec.Parse(s)
+			if err == nil && validate != nil {
+				err = validate(value)
 			}
-			return string(b)
+			return err
 		},
+		GetFunc: func() string { return codec.Format(*prop) },
 		SetFunc: func(s string) error {
-			var value T
-			if err := json.Unmarshal([]byte(s), &value); err != nil {
+			value, err := codec.Parse(s)
+			if err != nil {
 				return err
 			}
 			if validate != nil {
@@ -73,7 +85,7 @@ func RegisterJSONSettingWithValidator[T any](name, description string, prop *T,
 			*prop = value
 			return nil
 		},
-	})
+	}, opts))
 }
 
 func jsonValueToString(value any) (string, error) {
@@ -91,357 +103,201 @@ func jsonValueToString(value any) (string, error) {
 	}
 }
 
+// RegisterIntSetting is Registry.RegisterIntSetting on the default registry.
+func RegisterIntSetting(name string, description string, prop *int, opts ...Option) {
+	defaultRegistry.RegisterIntSetting(name, description, prop, opts...)
+}
+
 // RegisterIntSetting registers an integer setting with a name, description, and a pointer to the integer property.
-func RegisterIntSetting(name string, description string, prop *int) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc: func() string {
-			return strconv.Itoa(*prop)
-		},
-		SetFunc: func(s string) error {
-			i, err := strconv.Atoi(s)
-			if err != nil {
-				return err
-			}
-			*prop = i
-			return nil
-		},
-	})
+func (r *Registry) RegisterIntSetting(name string, description string, prop *int, opts ...Option) {
+	registerPointer(r, name, description, prop, IntCodec, opts...)
+}
+
+// RegisterBoolSetting is Registry.RegisterBoolSetting on the default registry.
+func RegisterBoolSetting(name string, description string, prop *bool, opts ...Option) {
+	defaultRegistry.RegisterBoolSetting(name, description, prop, opts...)
 }
 
 // RegisterBoolSetting registers a boolean setting with a specified name, description, and pointer to the bool property.
-func RegisterBoolSetting(name string, description string, prop *bool) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc: func() string {
-			return strconv.FormatBool(*prop)
-		},
-		SetFunc: func(s string) error {
-			b, err := strconv.ParseBool(s)
-			if err != nil {
-				return err
-			}
-			*prop = b
-			return nil
-		},
-	})
+func (r *Registry) RegisterBoolSetting(name string, description string, prop *bool, opts ...Option) {
+	registerPointer(r, name, description, prop, BoolCodec, opts...)
+}
+
+// RegisterUintSetting is Registry.RegisterUintSetting on the default registry.
+func RegisterUintSetting(name string, description string, prop *uint, opts ...Option) {
+	defaultRegistry.RegisterUintSetting(name, description, prop, opts...)
 }
 
 // RegisterUintSetting registers an unsigned integer setting with a specified name, description, and pointer to the uint property.
-func RegisterUintSetting(name string, description string, prop *uint) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc: func() string {
-			return strconv.FormatUint(uint64(*prop), 10)
-		},
-		SetFunc: func(s string) error {
-			u, err := strconv.ParseUint(s, 10, 64)
-			if err != nil {
-				return err
-			}
-			*prop = uint(u)
-			return nil
-		},
-	})
+func (r *Registry) RegisterUintSetting(name string, description string, prop *uint, opts ...Option) {
+	registerPointer(r, name, description, prop, UintCodec, opts...)
+}
+
+// RegisterFloatSetting is Registry.RegisterFloatSetting on the default registry.
+func RegisterFloatSetting(name string, description string, prop *float64, opts ...Option) {
+	defaultRegistry.RegisterFloatSetting(name, description, prop, opts...)
 }
 
 // RegisterFloatSetting registers a float64 setting with a specified name, description, and pointer to the float64 property.
-func RegisterFloatSetting(name string, description string, prop *float64) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc: func() string {
-			return strconv.FormatFloat(*prop, 'f', -1, 64)
-		},
-		SetFunc: func(s string) error {
-			f, err := strconv.ParseFloat(s, 64)
-			if err != nil {
-				return err
-			}
-			*prop = f
-			return nil
-		},
-	})
+func (r *Registry) RegisterFloatSetting(name string, description string, prop *float64, opts ...Option) {
+	registerPointer(r, name, description, prop, Float64Codec, opts...)
+}
+
+// RegisterDurationSetting is Registry.RegisterDurationSetting on the default registry.
+func RegisterDurationSetting(name string, description string, prop *time.Duration, opts ...Option) {
+	defaultRegistry.RegisterDurationSetting(name, description, prop, opts...)
 }
 
 // RegisterDurationSetting registers a time.Duration setting with a specified name, description, and pointer to the time.Duration property.
-func RegisterDurationSetting(name string, description string, prop *time.Duration) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc: func() string {
-			return prop.String()
-		},
-		SetFunc: func(s string) error {
-			d, err := time.ParseDuration(s)
-			if err != nil {
-				return err
-			}
-			*prop = d
-			return nil
-		},
-	})
+func (r *Registry) RegisterDurationSetting(name string, description string, prop *time.Duration, opts ...Option) {
+	registerPointer(r, name, description, prop, DurationCodec, opts...)
+}
+
+// RegisterInt32Setting is Registry.RegisterInt32Setting on the default registry.
+func RegisterInt32Setting(name string, description string, prop *int32, opts ...Option) {
+	defaultRegistry.RegisterInt32Setting(name, description, prop, opts...)
 }
 
 // RegisterInt32Setting registers a 32-bit integer setting with a specified name, description, and pointer to the int32 property.
-func RegisterInt32Setting(name string, description string, prop *int32) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc: func() string {
-			return strconv.FormatInt(int64(*prop), 10)
-		},
-		SetFunc: func(s string) error {
-			i, err := strconv.ParseInt(s, 10, 32)
-			if err != nil {
-				return err
-			}
-			*prop = int32(i)
-			return nil
-		},
-	})
+func (r *Registry) RegisterInt32Setting(name string, description string, prop *int32, opts ...Option) {
+	registerPointer(r, name, description, prop, Int32Codec, opts...)
+}
+
+// RegisterInt64Setting is Registry.RegisterInt64Setting on the default registry.
+func RegisterInt64Setting(name string, description string, prop *int64, opts ...Option) {
+	defaultRegistry.RegisterInt64Setting(name, description, prop, opts...)
 }
 
 // RegisterInt64Setting registers a 64-bit integer setting with a specified name, description, and pointer to the int64 property.
-func RegisterInt64Setting(name string, description string, prop *int64) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc: func() string {
-			return strconv.FormatInt(*prop, 10)
-		},
-		SetFunc: func(s string) error {
-			i, err := strconv.ParseInt(s, 10, 64)
-			if err != nil {
-				return err
-			}
-			*prop = i
-			return nil
-		},
-	})
+func (r *Registry) RegisterInt64Setting(name string, description string, prop *int64, opts ...Option) {
+	registerPointer(r, name, description, prop, Int64Codec, opts...)
+}
+
+// RegisterFloat32Setting is Registry.RegisterFloat32Setting on the default registry.
+func RegisterFloat32Setting(name string, description string, prop *float32, opts ...Option) {
+	defaultRegistry.RegisterFloat32Setting(name, description, prop, opts...)
 }
 
 // RegisterFloat32Setting registers a 32-bit float setting with a specified name, description, and pointer to the float32 property.
-func RegisterFloat32Setting(name string, description string, prop *float32) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc: func() string {
-			return strconv.FormatFloat(float64(*prop), 'f', -1, 32)
-		},
-		SetFunc: func(s string) error {
-			f, err := strconv.ParseFloat(s, 32)
-			if err != nil {
-				return err
-			}
-			*prop = float32(f)
-			return nil
-		},
-	})
+func (r *Registry) RegisterFloat32Setting(name string, description string, prop *float32, opts ...Option) {
+	registerPointer(r, name, description, prop, Float32Codec, opts...)
+}
+
+// RegisterInt8Setting is Registry.RegisterInt8Setting on the default registry.
+func RegisterInt8Setting(name, description string, prop *int8, opts ...Option) {
+	defaultRegistry.RegisterInt8Setting(name, description, prop, opts...)
 }
 
 // RegisterInt8Setting registers an 8-bit integer setting.
-func RegisterInt8Setting(name, description string, prop *int8) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc:     func() string { return strconv.FormatInt(int64(*prop), 10) },
-		SetFunc: func(s string) error {
-			i, err := strconv.ParseInt(s, 10, 8)
-			if err != nil {
-				return err
-			}
-			*prop = int8(i)
-			return nil
-		},
-	})
+func (r *Registry) RegisterInt8Setting(name, description string, prop *int8, opts ...Option) {
+	registerPointer(r, name, description, prop, Int8Codec, opts...)
+}
+
+// RegisterInt16Setting is Registry.RegisterInt16Setting on the default registry.
+func RegisterInt16Setting(name, description string, prop *int16, opts ...Option) {
+	defaultRegistry.RegisterInt16Setting(name, description, prop, opts...)
 }
 
 // RegisterInt16Setting registers a 16-bit integer setting.
-func RegisterInt16Setting(name, description string, prop *int16) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc:     func() string { return strconv.FormatInt(int64(*prop), 10) },
-		SetFunc: func(s string) error {
-			i, err := strconv.ParseInt(s, 10, 16)
-			if err != nil {
-				return err
-			}
-			*prop = int16(i)
-			return nil
-		},
-	})
+func (r *Registry) RegisterInt16Setting(name, description string, prop *int16, opts ...Option) {
+	registerPointer(r, name, description, prop, Int16Codec, opts...)
+}
+
+// RegisterUint8Setting is Registry.RegisterUint8Setting on the default registry.
+func RegisterUint8Setting(name, description string, prop *uint8, opts ...Option) {
+	defaultRegistry.RegisterUint8Setting(name, description, prop, opts...)
 }
 
 // RegisterUint8Setting registers an 8-bit unsigned integer setting.
-func RegisterUint8Setting(name, description string, prop *uint8) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc:     func() string { return strconv.FormatUint(uint64(*prop), 10) },
-		SetFunc: func(s string) error {
-			u, err := strconv.ParseUint(s, 10, 8)
-			if err != nil {
-				return err
-			}
-			*prop = uint8(u)
-			return nil
-		},
-	})
+func (r *Registry) RegisterUint8Setting(name, description string, prop *uint8, opts ...Option) {
+	registerPointer(r, name, description, prop, Uint8Codec, opts...)
+}
+
+// RegisterUint16Setting is Registry.RegisterUint16Setting on the default registry.
+func RegisterUint16Setting(name, description string, prop *uint16, opts ...Option) {
+	defaultRegistry.RegisterUint16Setting(name, description, prop, opts...)
 }
 
 // RegisterUint16Setting registers a 16-bit unsigned integer setting.
-func RegisterUint16Setting(name, description string, prop *uint16) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc:     func() string { return strconv.FormatUint(uint64(*prop), 10) },
-		SetFunc: func(s string) error {
-			u, err := strconv.ParseUint(s, 10, 16)
-			if err != nil {
-				return err
-			}
-			*prop = uint16(u)
-			return nil
-		},
-	})
+func (r *Registry) RegisterUint16Setting(name, description string, prop *uint16, opts ...Option) {
+	registerPointer(r, name, description, prop, Uint16Codec, opts...)
+}
+
+// RegisterUint32Setting is Registry.RegisterUint32Setting on the default registry.
+func RegisterUint32Setting(name, description string, prop *uint32, opts ...Option) {
+	defaultRegistry.RegisterUint32Setting(name, description, prop, opts...)
 }
 
 // RegisterUint32Setting registers a 32-bit unsigned integer setting.
-func RegisterUint32Setting(name, description string, prop *uint32) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc:     func() string { return strconv.FormatUint(uint64(*prop), 10) },
-		SetFunc: func(s string) error {
-			u, err := strconv.ParseUint(s, 10, 32)
-			if err != nil {
-				return err
-			}
-			*prop = uint32(u)
-			return nil
-		},
-	})
+func (r *Registry) RegisterUint32Setting(name, description string, prop *uint32, opts ...Option) {
+	registerPointer(r, name, description, prop, Uint32Codec, opts...)
+}
+
+// RegisterUint64Setting is Registry.RegisterUint64Setting on the default registry.
+func RegisterUint64Setting(name, description string, prop *uint64, opts ...Option) {
+	defaultRegistry.RegisterUint64Setting(name, description, prop, opts...)
 }
 
 // RegisterUint64Setting registers a 64-bit unsigned integer setting.
-func RegisterUint64Setting(name, description string, prop *uint64) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc:     func() string { return strconv.FormatUint(*prop, 10) },
-		SetFunc: func(s string) error {
-			u, err := strconv.ParseUint(s, 10, 64)
-			if err != nil {
-				return err
-			}
-			*prop = u
-			return nil
-		},
-	})
+func (r *Registry) RegisterUint64Setting(name, description string, prop *uint64, opts ...Option) {
+	registerPointer(r, name, description, prop, Uint64Codec, opts...)
+}
+
+// RegisterTimeSetting is Registry.RegisterTimeSetting on the default registry.
+func RegisterTimeSetting(name, description string, prop *time.Time, opts ...Option) {
+	defaultRegistry.RegisterTimeSetting(name, description, prop, opts...)
 }
 
 // RegisterTimeSetting registers a time.Time setting using RFC3339 format.
-func RegisterTimeSetting(name, description string, prop *time.Time) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc:     func() string { return prop.Format(time.RFC3339) },
-		SetFunc: func(s string) error {
-			t, err := time.Parse(time.RFC3339, s)
-			if err != nil {
-				return err
-			}
-			*prop = t
-			return nil
-		},
-	})
+func (r *Registry) RegisterTimeSetting(name, description string, prop *time.Time, opts ...Option) {
+	registerPointer(r, name, description, prop, TimeCodec, opts...)
+}
+
+// RegisterStringSliceSetting is Registry.RegisterStringSliceSetting on the default registry.
+func RegisterStringSliceSetting(name, description string, prop *[]string, opts ...Option) {
+	defaultRegistry.RegisterStringSliceSetting(name, description, prop, opts...)
 }
 
 // RegisterStringSliceSetting registers a string slice setting (comma-separated).
-func RegisterStringSliceSetting(name, description string, prop *[]string) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc:     func() string { return strings.Join(*prop, ",") },
-		SetFunc: func(s string) error {
-			if s == "" {
-				*prop = nil
-			} else {
-				*prop = strings.Split(s, ",")
-			}
-			return nil
-		},
-	})
+func (r *Registry) RegisterStringSliceSetting(name, description string, prop *[]string, opts ...Option) {
+	registerPointer(r, name, description, prop, StringSliceCodec, opts...)
+}
+
+// RegisterIPSetting is Registry.RegisterIPSetting on the default registry.
+func RegisterIPSetting(name, description string, prop *net.IP, opts ...Option) {
+	defaultRegistry.RegisterIPSetting(name, description, prop, opts...)
 }
 
 // RegisterIPSetting registers a net.IP setting.
-func RegisterIPSetting(name, description string, prop *net.IP) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc:     func() string { return prop.String() },
-		SetFunc: func(s string) error {
-			ip := net.ParseIP(s)
-			if ip == nil {
-				return fmt.Errorf("invalid IP: %q", s)
-			}
-			*prop = ip
-			return nil
-		},
-	})
+func (r *Registry) RegisterIPSetting(name, description string, prop *net.IP, opts ...Option) {
+	registerPointer(r, name, description, prop, IPCodec, opts...)
+}
+
+// RegisterIPNetSetting is Registry.RegisterIPNetSetting on the default registry.
+func RegisterIPNetSetting(name, description string, prop *net.IPNet, opts ...Option) {
+	defaultRegistry.RegisterIPNetSetting(name, description, prop, opts...)
 }
 
 // RegisterIPNetSetting registers a net.IPNet setting (CIDR format).
-func RegisterIPNetSetting(name, description string, prop *net.IPNet) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc:     func() string { return prop.String() },
-		SetFunc: func(s string) error {
-			_, ipnet, err := net.ParseCIDR(s)
-			if err != nil {
-				return err
-			}
-			*prop = *ipnet
-			return nil
-		},
-	})
+func (r *Registry) RegisterIPNetSetting(name, description string, prop *net.IPNet, opts ...Option) {
+	registerPointer(r, name, description, prop, IPNetCodec, opts...)
+}
+
+// RegisterURLSetting is Registry.RegisterURLSetting on the default registry.
+func RegisterURLSetting(name, description string, prop *url.URL, opts ...Option) {
+	defaultRegistry.RegisterURLSetting(name, description, prop, opts...)
 }
 
 // RegisterURLSetting registers a url.URL setting.
-func RegisterURLSetting(name, description string, prop *url.URL) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc:     func() string { return prop.String() },
-		SetFunc: func(s string) error {
-			u, err := url.Parse(s)
-			if err != nil {
-				return err
-			}
-			*prop = *u
-			return nil
-		},
-	})
+func (r *Registry) RegisterURLSetting(name, description string, prop *url.URL, opts ...Option) {
+	registerPointer(r, name, description, prop, URLCodec, opts...)
 }
 
-func RegisterCronSetting(name, description string, cronString *string) {
-	RegisterSetting(&Setting{
-		Name:        name,
-		Description: description,
-		GetFunc:     func() string { return *cronString },
-		SetFunc: func(s string) error {
-			parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
-			if _, err := parser.Parse(s); err != nil {
-				return fmt.Errorf("invalid cron expression: %w", err)
-			}
-			*cronString = s
-			return nil
-		},
-	})
+// RegisterCronSetting is Registry.RegisterCronSetting on the default registry.
+func RegisterCronSetting(name, description string, cronString *string, opts ...Option) {
+	defaultRegistry.RegisterCronSetting(name, description, cronString, opts...)
+}
+
+func (r *Registry) RegisterCronSetting(name, description string, cronString *string, opts ...Option) {
+	registerPointer(r, name, description, cronString, CronCodec, opts...)
 }
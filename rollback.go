@@ -0,0 +1,276 @@
+package app_settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dan-sherwin/go-app-settings/db/models"
+)
+
+// rollbackTarget selects the point a rollback returns to: the state after the history entry with ID version,
+// the state at time at, or, when both are zero, the state before the latest change.
+type rollbackTarget struct {
+	version int64
+	at      time.Time
+}
+
+func (t rollbackTarget) String() string {
+	switch {
+	case t.version != 0:
+		return "version " + strconv.FormatInt(t.version, 10)
+	case !t.at.IsZero():
+		return t.at.Format(time.RFC3339)
+	}
+	return "the previous value"
+}
+
+// stateAfter returns the saved state a history entry left behind. Entries recorded before NewSaved existed
+// only removed a value as a delete.
+func stateAfter(e *models.AppSettingHistory) savedState {
+	if e.NewSaved != nil {
+		return savedState{value: e.NewValue, saved: *e.NewSaved}
+	}
+	return savedState{value: e.NewValue, saved: e.Operation != OpDelete}
+}
+
+// stateBefore returns the saved state a history entry replaced. Entries recorded before OldSaved existed
+// fall back to treating an empty old value as no saved value.
+func stateBefore(e *models.AppSettingHistory) savedState {
+	if e.OldSaved != nil {
+		return savedState{value: e.OldValue, saved: *e.OldSaved}
+	}
+	return savedState{value: e.OldValue, saved: e.OldValue != "" || e.Operation == OpDelete}
+}
+
+// stateOf returns the saved state of the named setting at the target from its history, oldest entry first.
+// It returns false when the history does not reach back to the target.
+func (t rollbackTarget) stateOf(name string, entries []*models.AppSettingHistory) (savedState, bool, error) {
+	if len(entries) == 0 {
+		if t.version != 0 || t.at.IsZero() {
+			return savedState{}, false, fmt.Errorf("setting %s has no recorded changes", name)
+		}
+		return savedState{}, false, nil
+	}
+	switch {
+	case t.version != 0:
+		for _, e := range entries {
+			if e.ID == t.version {
+				return stateAfter(e), true, nil
+			}
+		}
+		return savedState{}, false, fmt.Errorf("setting %s has no version %d in its history", name, t.version)
+	case !t.at.IsZero():
+		for i := len(entries) - 1; i >= 0; i-- {
+			if !entries[i].ChangedAt.After(t.at) {
+				return stateAfter(entries[i]), true, nil
+			}
+		}
+		return stateBefore(entries[0]), true, nil
+	}
+	return stateBefore(entries[len(entries)-1]), true, nil
+}
+
+// Rollback undoes the latest change of a setting of the default registry. See Registry.Rollback.
+func Rollback(name string) error {
+	return defaultRegistry.Rollback(name)
+}
+
+// RollbackContext undoes the latest change of a setting of the default registry. See Registry.RollbackContext.
+func RollbackContext(ctx context.Context, name string) error {
+	return defaultRegistry.RollbackContext(ctx, name)
+}
+
+// RollbackToVersion rolls back a setting of the default registry. See Registry.RollbackToVersion.
+func RollbackToVersion(name string, version int64) error {
+	return defaultRegistry.RollbackToVersion(name, version)
+}
+
+// RollbackToVersionContext rolls back a setting of the default registry. See
+// Registry.RollbackToVersionContext.
+func RollbackToVersionContext(ctx context.Context, name string, version int64) error {
+	return defaultRegistry.RollbackToVersionContext(ctx, name, version)
+}
+
+// RollbackToTime rolls back a setting of the default registry. See Registry.RollbackToTime.
+func RollbackToTime(name string, at time.Time) error {
+	return defaultRegistry.RollbackToTime(name, at)
+}
+
+// RollbackToTimeContext rolls back a setting of the default registry. See Registry.RollbackToTimeContext.
+func RollbackToTimeContext(ctx context.Context, name string, at time.Time) error {
+	return defaultRegistry.RollbackToTimeContext(ctx, name, at)
+}
+
+// RollbackAll rolls back every setting of the default registry. See Registry.RollbackAll.
+func RollbackAll(at time.Time) error {
+	return defaultRegistry.RollbackAll(at)
+}
+
+// RollbackAllContext rolls back every setting of the default registry. See Registry.RollbackAllContext.
+func RollbackAllContext(ctx context.Context, at time.Time) error {
+	return defaultRegistry.RollbackAllContext(ctx, at)
+}
+
+// Rollback undoes the latest recorded change of the named setting, restoring the saved value before it.
+func (r *Registry) Rollback(name string) error {
+	return r.RollbackContext(context.Background(), name)
+}
+
+// RollbackContext is Rollback with a context bounding the database work and the hooks.
+func (r *Registry) RollbackContext(ctx context.Context, name string) error {
+	_, err := r.rollback(ctx, []string{name}, rollbackTarget{}, "")
+	return err
+}
+
+// RollbackToVersion restores the saved value the named setting had right after the history entry version.
+func (r *Registry) RollbackToVersion(name string, version int64) error {
+	return r.RollbackToVersionContext(context.Background(), name, version)
+}
+
+// RollbackToVersionContext is RollbackToVersion with a context bounding the database work and the hooks.
+func (r *Registry) RollbackToVersionContext(ctx context.Context, name string, version int64) error {
+	_, err := r.rollback(ctx, []string{name}, rollbackTarget{version: version}, "")
+	return err
+}
+
+// RollbackToTime restores the saved value the named setting had at the given time.
+func (r *Registry) RollbackToTime(name string, at time.Time) error {
+	return r.RollbackToTimeContext(context.Background(), name, at)
+}
+
+// RollbackToTimeContext is RollbackToTime with a context bounding the database work and the hooks.
+func (r *Registry) RollbackToTimeContext(ctx context.Context, name string, at time.Time) error {
+	_, err := r.rollback(ctx, []string{name}, rollbackTarget{at: at}, "")
+	return err
+}
+
+// RollbackAll restores every setting to the saved value it had at the given time, in one transaction.
+func (r *Registry) RollbackAll(at time.Time) error {
+	return r.RollbackAllContext(context.Background(), at)
+}
+
+// RollbackAllContext is RollbackAll with a context bounding the database work and the hooks.
+func (r *Registry) RollbackAllContext(ctx context.Context, at time.Time) error {
+	if at.IsZero() {
+		return errors.New("RollbackAll requires a time")
+	}
+	names := []string{}
+	for _, s := range r.snapshot() {
+		names = append(names, s.Name)
+	}
+	_, err := r.rollback(ctx, names, rollbackTarget{at: at}, "")
+	return err
+}
+
+// rollback returns the named settings to their saved state at target and recorded in the history. Each
+// change is conditional on the version the saved value had when the plan was made, so a change committed
+// meanwhile fails the rollback with a *ConflictError instead of being overwritten.
+func (r *Registry) rollback(ctx context.Context, names []string, target rollbackTarget, reason string) ([]savedChange, error) {
+	store, err := r.storeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The saved rows are read before the history: a change committed in between then shows up as a version
+	// the rows do not have.
+	rows, err := r.loadValues(store)
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]savedState{}
+	versions := map[string]int64{}
+	for _, row := range rows {
+		current[row.Key] = savedState{value: row.Value, saved: true}
+		versions[row.Key] = row.Version
+	}
+	entries, err := r.HistoryContext(ctx, "", time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	history := map[string][]*models.AppSettingHistory{}
+	for _, e := range entries {
+		history[e.Key] = append(history[e.Key], e)
+	}
+	plan := []savedChange{}
+	for _, name := range names {
+		setting, err := r.GetSetting(name)
+		if err != nil {
+			return nil, err
+		}
+		state, known, err := target.stateOf(name, history[name])
+		if err != nil {
+			return nil, err
+		}
+		if known && state != current[name] {
+			if err := r.checkNotOverridden(setting); err != nil {
+				return nil, err
+			}
+			version := versions[name]
+			plan = append(plan, savedChange{setting: setting, state: state, ifVersion: &version})
+		}
+	}
+	if reason == "" {
+		reason = "rollback to " + target.String()
+	}
+	if err := r.commitSaved(ctx, store, plan, OpRollback, reason); err != nil {
+		return nil, fmt.Errorf("rollback failed, nothing changed: %w", err)
+	}
+	return plan, nil
+}
+
+// Run rolls back one setting, or every visible setting with --all, and prints what was restored.
+func (c *SettingsRollbackCommand) Run(r *Registry) error {
+	var target rollbackTarget
+	if version, err := strconv.ParseInt(c.To, 10, 64); err == nil && !c.All {
+		target.version = version
+	} else {
+		at, err := parseTimeFlag("to", c.To)
+		if err != nil {
+			return printAndReturnErr(err)
+		}
+		target.at = at
+	}
+	var names []string
+	switch {
+	case c.All && c.Setting != "":
+		return printAndReturnErr(errors.New("specify either a setting name or --all"))
+	case c.All:
+		if target.at.IsZero() {
+			return printAndReturnErr(errors.New("--all requires --to <time>"))
+		}
+		for _, s := range r.snapshot() {
+			if !s.Hidden {
+				names = append(names, s.Name)
+			}
+		}
+	case c.Setting == "":
+		return printAndReturnErr(errors.New("specify either a setting name or --all"))
+	default:
+		setting, err := r.getCLISetting(c.Setting)
+		if err != nil {
+			return printAndReturnErr(err)
+		}
+		names = []string{setting.Name}
+	}
+	ctx := context.Background()
+	restored, err := r.rollback(ctx, names, target, c.Reason)
+	if err != nil {
+		return printAndReturnErr(err)
+	}
+	if len(restored) == 0 {
+		fmt.Println("Nothing to roll back")
+		return nil
+	}
+	names = names[:0]
+	for _, p := range restored {
+		if p.state.saved {
+			fmt.Printf("Setting %s rolled back to %s\n", p.setting.Name, p.setting.display(p.state.value))
+		} else {
+			fmt.Printf("Setting %s rolled back to no saved value\n", p.setting.Name)
+		}
+		names = append(names, p.setting.Name)
+	}
+	return r.applyToDaemon(ctx, names...)
+}
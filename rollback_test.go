@@ -0,0 +1,224 @@
+package app_settings
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dan-sherwin/go-app-settings/db/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRollback_UndoVersionAndTime(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var region string
+	r.RegisterStringSetting("region", "Region", &region)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	var changes []Change
+	r.OnChange("region", func(old, new string) { changes = append(changes, Change{Name: "region", Old: old, New: new}) })
+	for _, v := range []string{"eu", "us"} {
+		if err := r.SetSetting("region", v); err != nil {
+			t.Fatalf("SetSetting failed: %v", err)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	between := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if err := r.SetSetting("region", "ap"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+
+	if err := r.Rollback("region"); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if region != "us" {
+		t.Fatalf("expected undo to restore us, got %q", region)
+	}
+	entries, _ := r.History("region", time.Time{})
+	last := entries[len(entries)-1]
+	if last.Operation != OpRollback || last.OldValue != "ap" || last.NewValue != "us" || last.Reason == "" {
+		t.Fatalf("rollback not recorded: %#v", last)
+	}
+
+	if err := r.RollbackToVersion("region", entries[0].ID); err != nil {
+		t.Fatalf("RollbackToVersion failed: %v", err)
+	}
+	if region != "eu" {
+		t.Fatalf("expected version %d to restore eu, got %q", entries[0].ID, region)
+	}
+	if err := r.RollbackToTime("region", between); err != nil {
+		t.Fatalf("RollbackToTime failed: %v", err)
+	}
+	if region != "us" {
+		t.Fatalf("expected the value at %s to be us, got %q", between, region)
+	}
+	if n := len(changes); n != 6 || changes[n-1].Old != "eu" || changes[n-1].New != "us" {
+		t.Fatalf("unexpected change notifications: %#v", changes)
+	}
+
+	if err := r.RollbackToVersion("region", 999); err == nil {
+		t.Fatalf("expected error for unknown version")
+	}
+	if err := r.RollbackToTime("region", between.Add(-time.Hour)); err != nil {
+		t.Fatalf("RollbackToTime failed: %v", err)
+	}
+	if rows, _ := r.store.AppSetting.Find(); len(rows) != 0 {
+		t.Fatalf("expected rollback before the first save to remove the saved value: %#v", rows)
+	}
+}
+
+func TestRollback_AllIsAtomic(t *testing.T) {
+	r := NewRegistry()
+	region, level := "", "info"
+	r.RegisterStringSetting("region", "Region", &region)
+	r.RegisterEnumSetting("log.level", "Log level", &level, []string{"debug", "info", "trace"})
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.SetSetting("region", "eu"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if err := r.SetSetting("log.level", "trace"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	at := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if err := r.SetSetting("region", "us"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if err := r.SetSetting("log.level", "debug"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+
+	// A value the validator no longer accepts makes the whole rollback fail without changing anything.
+	setting, _ := r.GetSetting("log.level")
+	validators := setting.Validators
+	setting.Validators = append(validators, Validator{Rule: "no-trace", Func: func(v string) error {
+		if v == "trace" {
+			return errors.New("trace is disabled")
+		}
+		return nil
+	}})
+	if err := r.RollbackAll(at); err == nil {
+		t.Fatalf("expected RollbackAll to fail validation")
+	}
+	if region != "us" || level != "debug" {
+		t.Fatalf("failed rollback changed values: region=%q level=%q", region, level)
+	}
+	setting.Validators = validators
+
+	out := captureStdout(func() {
+		if err := (&SettingsRollbackCommand{All: true, To: at.Format(time.RFC3339Nano)}).Run(r); err != nil {
+			t.Errorf("rollback --all failed: %v", err)
+		}
+	})
+	if region != "eu" || level != "trace" {
+		t.Fatalf("rollback --all did not restore values: region=%q level=%q", region, level)
+	}
+	if !strings.Contains(out, "Setting region rolled back to eu") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+	out = captureStdout(func() {
+		_ = (&SettingsRollbackCommand{All: true, To: at.Format(time.RFC3339Nano)}).Run(r)
+	})
+	if !strings.Contains(out, "Nothing to roll back") {
+		t.Fatalf("expected nothing to roll back: %s", out)
+	}
+
+	entries, _ := r.History("region", time.Time{})
+	out = captureStdout(func() {
+		if err := (&SettingsRollbackCommand{Setting: "region", To: strconv.FormatInt(entries[1].ID, 10), Reason: "back to us"}).Run(r); err != nil {
+			t.Errorf("rollback --to version failed: %v", err)
+		}
+	})
+	if region != "us" {
+		t.Fatalf("rollback --to version did not restore us, got %q", region)
+	}
+	if entries, _ = r.History("region", time.Time{}); entries[len(entries)-1].Reason != "back to us" {
+		t.Fatalf("reason not recorded: %#v", entries[len(entries)-1])
+	}
+	if err := (&SettingsRollbackCommand{All: true}).Run(r); err == nil {
+		t.Fatalf("expected --all without --to to fail")
+	}
+}
+
+func TestRollback_RestoresSavedEmptyValue(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	region := "eu"
+	r.RegisterStringSetting("region", "Region", &region)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	for _, v := range []string{"", "us"} {
+		if err := r.SetSetting("region", v); err != nil {
+			t.Fatalf("SetSetting failed: %v", err)
+		}
+	}
+	if err := r.Rollback("region"); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	rows, _ := r.store.AppSetting.Find()
+	if region != "" || len(rows) != 1 || rows[0].Value != "" {
+		t.Fatalf("expected the saved empty value back, got region=%q rows=%#v", region, rows)
+	}
+
+	entries, _ := r.History("region", time.Time{})
+	if err := r.RollbackToTime("region", entries[0].ChangedAt.Add(-time.Second)); err != nil {
+		t.Fatalf("RollbackToTime failed: %v", err)
+	}
+	if rows, _ := r.store.AppSetting.Find(); region != "eu" || len(rows) != 0 {
+		t.Fatalf("expected no saved value before the first save, got region=%q rows=%#v", region, rows)
+	}
+}
+
+func TestRollback_ConcurrentChangeIsAConflict(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var region string
+	r.RegisterStringSetting("region", "Region", &region)
+	gormDB, err := gorm.Open(sqlite.Open(tempDBPath(t)), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db failed: %v", err)
+	}
+	if err := r.SetupWithDB(gormDB, SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	for _, v := range []string{"eu", "us"} {
+		if err := r.SetSetting("region", v); err != nil {
+			t.Fatalf("SetSetting failed: %v", err)
+		}
+	}
+	// Another writer saves a value after the rollback read the history and before it writes.
+	raced := false
+	err = gormDB.Callback().Query().After("gorm:query").Register("test:race", func(tx *gorm.DB) {
+		if raced || tx.Statement.Table != models.TableNameAppSettingHistory {
+			return
+		}
+		raced = true
+		gormDB.Exec("UPDATE app_settings SET value = 'ap', version = 999 WHERE key = 'region'")
+	})
+	if err != nil {
+		t.Fatalf("register callback failed: %v", err)
+	}
+
+	err = r.Rollback("region")
+	var conflict *ConflictError
+	if !raced || !errors.As(err, &conflict) || conflict.Actual != 999 {
+		t.Fatalf("expected a ConflictError for the concurrent change, got %v", err)
+	}
+	saved, err := r.savedStates(r.store)
+	if err != nil {
+		t.Fatalf("savedStates failed: %v", err)
+	}
+	if saved["region"].value != "ap" || region != "us" {
+		t.Fatalf("rollback overwrote the concurrent change: saved %q, running %q", saved["region"].value, region)
+	}
+}
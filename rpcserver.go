@@ -0,0 +1,217 @@
+package app_settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/rpc"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultSocketMode lets the owner and the group of the application connect to the socket.
+const defaultSocketMode os.FileMode = 0o660
+
+// RPCServerOptions configures the socket server started by ServeRPC.
+type RPCServerOptions struct {
+	// Mode is the permission of the socket file; zero means 0660. Connecting requires write permission.
+	Mode os.FileMode
+	// AllowedUIDs and AllowedGIDs restrict connections to peers running as one of these users or with one of
+	// these primary groups, checked with SO_PEERCRED. The user running the application is always allowed.
+	// Both empty allows every peer that may open the socket. Restrictions are only supported on Linux.
+	AllowedUIDs []int
+	AllowedGIDs []int
+}
+
+// allows reports whether a peer with the given credentials may connect.
+func (o RPCServerOptions) allows(uid, gid int) bool {
+	return uid == os.Getuid() || slices.Contains(o.AllowedUIDs, uid) || slices.Contains(o.AllowedGIDs, gid)
+}
+
+// rpcListener is a running socket server and its open connections.
+type rpcListener struct {
+	listener net.Listener
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// ServeRPC serves the default registry on its socket. See Registry.ServeRPC.
+func ServeRPC(opts RPCServerOptions) error {
+	return defaultRegistry.ServeRPC(opts)
+}
+
+// ServeRPC listens on SettingsOptions.RpcSocketPathToListRunningSettings and serves the running settings
+// to `settings list running` and the other settings commands until Close or Shutdown. A socket file left
+// behind by an application that is no longer running is removed first; a socket something still listens on
+// and any other kind of file are left alone and reported as errors. Call it from the long-running
+// application only, not from CLI invocations that share its Setup.
+func (r *Registry) ServeRPC(opts RPCServerOptions) error {
+	r.mu.RLock()
+	server, socketPath, serving := r.rpcServer, r.socketPath, r.serving
+	r.mu.RUnlock()
+	if server == nil || socketPath == "" {
+		return errors.New("ServeRPC requires SettingsOptions.RpcSocketPathToListRunningSettings")
+	}
+	if serving != nil {
+		return fmt.Errorf("already serving settings on %s", socketPath)
+	}
+	if (len(opts.AllowedUIDs) > 0 || len(opts.AllowedGIDs) > 0) && !peerCredentialsSupported {
+		return errors.New("AllowedUIDs and AllowedGIDs are not supported on this platform")
+	}
+	if err := removeStaleSocket(socketPath); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", socketPath, err)
+	}
+	mode := opts.Mode
+	if mode == 0 {
+		mode = defaultSocketMode
+	}
+	if err := os.Chmod(socketPath, mode); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("set permissions of %s: %w", socketPath, err)
+	}
+	s := &rpcListener{listener: listener, conns: map[net.Conn]struct{}{}}
+	r.mu.Lock()
+	if r.serving != nil {
+		r.mu.Unlock()
+		_ = listener.Close()
+		return fmt.Errorf("already serving settings on %s", socketPath)
+	}
+	r.serving = s
+	r.mu.Unlock()
+	go r.acceptRPC(s, server, opts)
+	return nil
+}
+
+// removeStaleSocket removes a socket file nothing listens on any more.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket; refusing to remove it", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("socket %s is in use by another process", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove stale socket %s: %w", path, err)
+	}
+	return nil
+}
+
+// acceptRPC serves the connections accepted by s until its listener is closed.
+func (r *Registry) acceptRPC(s *rpcListener, server *rpc.Server, opts RPCServerOptions) {
+	var delay time.Duration
+	for {
+		conn, err := s.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			// Back off on errors such as running out of file descriptors, as net/http does.
+			delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+			r.reportError(fmt.Errorf("accept settings RPC connection: %w", err))
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+		if len(opts.AllowedUIDs) > 0 || len(opts.AllowedGIDs) > 0 {
+			uid, gid, err := peerCredentials(conn)
+			if err == nil && !opts.allows(uid, gid) {
+				err = fmt.Errorf("peer uid %d gid %d is not allowed", uid, gid)
+			}
+			if err != nil {
+				r.reportError(fmt.Errorf("reject settings RPC connection: %w", err))
+				_ = conn.Close()
+				continue
+			}
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go func() {
+			defer s.wg.Done()
+			server.ServeConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// closeConns drops the open connections of s.
+func (s *rpcListener) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+// Shutdown stops the default registry. See Registry.Shutdown.
+func Shutdown(ctx context.Context) error {
+	return defaultRegistry.Shutdown(ctx)
+}
+
+// Shutdown is Close that lets open RPC connections finish first. It stops accepting connections, removes
+// the socket file and waits for the open connections to end until ctx is done, then drops them and returns
+// the context's error.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.stopTasks()
+	return r.stopRPC(ctx)
+}
+
+// stopRPC stops the server started by ServeRPC. A nil ctx drops open connections right away.
+func (r *Registry) stopRPC(ctx context.Context) error {
+	r.mu.Lock()
+	s := r.serving
+	r.serving = nil
+	r.mu.Unlock()
+	if s == nil {
+		return nil
+	}
+	// Closing the listener also removes the socket file.
+	err := s.listener.Close()
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	if ctx == nil {
+		s.closeConns()
+		s.wg.Wait()
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		s.closeConns()
+		<-done
+		return ctx.Err()
+	}
+}
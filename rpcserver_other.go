@@ -0,0 +1,15 @@
+//go:build !linux
+
+package app_settings
+
+import (
+	"errors"
+	"net"
+)
+
+const peerCredentialsSupported = false
+
+// peerCredentials is only implemented on Linux; ServeRPC rejects peer restrictions elsewhere.
+func peerCredentials(net.Conn) (int, int, error) {
+	return 0, 0, errors.New("peer credentials are not supported on this platform")
+}
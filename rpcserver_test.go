@@ -0,0 +1,123 @@
+package app_settings
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dan-sherwin/go-app-settings/db/models"
+)
+
+func TestServeRPC_Lifecycle(t *testing.T) {
+	t.Parallel()
+	socket := shortSocketPath(t)
+	// A socket file left behind by a crashed application.
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	r := NewRegistry()
+	region := "eu"
+	r.RegisterStringSetting("region", "Region", &region)
+	if err := r.ServeRPC(RPCServerOptions{}); err == nil {
+		t.Fatalf("expected ServeRPC to require a socket path")
+	}
+	if err := r.Setup(tempDBPath(t), SettingsOptions{RpcSocketPathToListRunningSettings: socket}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.ServeRPC(RPCServerOptions{Mode: 0o600}); err != nil {
+		t.Fatalf("ServeRPC failed: %v", err)
+	}
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("unexpected socket permissions: %v %v", info, err)
+	}
+	if err := r.ServeRPC(RPCServerOptions{}); err == nil {
+		t.Fatalf("expected error when already serving")
+	}
+	other := NewRegistry()
+	if err := other.Setup(tempDBPath(t), SettingsOptions{RpcSocketPathToListRunningSettings: socket}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := other.ServeRPC(RPCServerOptions{}); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("expected a socket in use to be left alone, got %v", err)
+	}
+
+	client, err := rpc.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	running := []models.AppSetting{}
+	if err := client.Call(rpcServiceName+".GetRunningSettings", &struct{}{}, &running); err != nil || len(running) != 1 {
+		t.Fatalf("GetRunningSettings failed: %v %#v", err, running)
+	}
+
+	// Shutdown waits for the open connection until the context ends, then drops it.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Shutdown to time out on the open connection, got %v", err)
+	}
+	if err := client.Call(rpcServiceName+".GetRunningSettings", &struct{}{}, &running); err == nil {
+		t.Fatalf("expected the connection to be closed")
+	}
+	_ = client.Close()
+	if _, err := os.Lstat(socket); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the socket file to be removed, got %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("second Close failed: %v", err)
+	}
+
+	plain := filepath.Join(filepath.Dir(socket), "plain")
+	writeFile(t, plain, "not a socket")
+	if err := removeStaleSocket(plain); err == nil {
+		t.Fatalf("expected a regular file to be left alone")
+	}
+}
+
+func TestRPCServerOptions_Allows(t *testing.T) {
+	t.Parallel()
+	opts := RPCServerOptions{AllowedUIDs: []int{1001}, AllowedGIDs: []int{50}}
+	other := os.Getuid() + 4242
+	cases := []struct {
+		uid, gid int
+		want     bool
+	}{
+		{os.Getuid(), 9999, true},
+		{1001, 9999, true},
+		{other, 50, true},
+		{other, 9999, false},
+	}
+	for _, c := range cases {
+		if got := opts.allows(c.uid, c.gid); got != c.want {
+			t.Fatalf("allows(%d, %d) = %v, want %v", c.uid, c.gid, got, c.want)
+		}
+	}
+}
+
+func TestSetup_PublishesDefaultRegistryOnDefaultServer(t *testing.T) {
+	resetDefaultRegistry()
+	region := "eu"
+	RegisterStringSetting("region", "Region", &region)
+	if err := Setup(tempDBPath(t), SettingsOptions{RpcSocketPathToListRunningSettings: shortSocketPath(t)}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	// Applications that serve rpc.DefaultServer on their own listener still answer `settings list running`.
+	server, conn := net.Pipe()
+	go rpc.ServeConn(server)
+	client := rpc.NewClient(conn)
+	defer client.Close()
+	var running []models.AppSetting
+	if err := client.Call(rpcServiceName+".GetRunningSettings", &struct{}{}, &running); err != nil {
+		t.Fatalf("GetRunningSettings on rpc.DefaultServer failed: %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+package app_settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dan-sherwin/go-app-settings/db"
+	"github.com/dan-sherwin/go-app-settings/db/models"
+)
+
+// validateScope checks that scope has the form KIND:ID, such as tenant:acme or user:42.
+func validateScope(scope string) error {
+	kind, id, ok := strings.Cut(scope, ":")
+	if !ok || kind == "" || id == "" || strings.ContainsAny(scope, ", \t\r\n") {
+		return fmt.Errorf("invalid scope %q: expected KIND:ID such as tenant:acme", scope)
+	}
+	return nil
+}
+
+// scopedName is the name a scoped value is encrypted and reported under.
+func scopedName(name, scope string) string {
+	return name + "@" + scope
+}
+
+// loadScoped reads the scoped overrides of the registered settings, decrypted, keyed by scope and then by
+// setting name.
+func (r *Registry) loadScoped(store *db.Store) (map[string]map[string]string, error) {
+	rows, err := store.AppSettingScoped.Find()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	c := r.cipher
+	r.mu.RUnlock()
+	scoped := map[string]map[string]string{}
+	for _, row := range rows {
+		if _, err := r.GetSetting(row.Key); err != nil {
+			continue
+		}
+		value, err := decodeValue(c, scopedName(row.Key, row.Scope), row.Value)
+		if err != nil {
+			return nil, err
+		}
+		if scoped[row.Scope] == nil {
+			scoped[row.Scope] = map[string]string{}
+		}
+		scoped[row.Scope][row.Key] = value
+	}
+	return scoped, nil
+}
+
+// refreshScoped replaces the in-memory scoped overrides with the ones saved in store.
+func (r *Registry) refreshScoped(store *db.Store) error {
+	scoped, err := r.loadScoped(store)
+	if err != nil {
+		return fmt.Errorf("Error getting scoped settings: %w", err)
+	}
+	r.mu.Lock()
+	r.scoped = scoped
+	r.mu.Unlock()
+	return nil
+}
+
+// GetScoped resolves a setting of the default registry in scopes. See Registry.GetScoped.
+func GetScoped(name string, scopes ...string) (string, error) {
+	return defaultRegistry.GetScoped(name, scopes...)
+}
+
+// GetScoped returns the value of the named setting in the first of scopes, most specific first, that
+// overrides it, and the value the setting runs with otherwise. A fallback chain of user, tenant, global and
+// default is GetScoped(name, "user:"+userID, "tenant:"+tenantID). Overrides are read from memory: they are
+// loaded by RetrieveAppSettings and kept current by SetScoped, ReloadSettings and the database watcher.
+func (r *Registry) GetScoped(name string, scopes ...string) (string, error) {
+	setting, err := r.GetSetting(name)
+	if err != nil {
+		return "", err
+	}
+	for _, scope := range scopes {
+		if err := validateScope(scope); err != nil {
+			return "", err
+		}
+	}
+	value, _ := r.resolveScoped(setting, scopes)
+	return value, nil
+}
+
+// resolveScoped returns the value of setting in the first of scopes that overrides it together with that
+// scope, or the running value and an empty scope.
+func (r *Registry) resolveScoped(setting *Setting, scopes []string) (string, string) {
+	r.mu.RLock()
+	for _, scope := range scopes {
+		if value, ok := r.scoped[scope][setting.Name]; ok {
+			r.mu.RUnlock()
+			return value, scope
+		}
+	}
+	r.mu.RUnlock()
+	return setting.GetFunc(), ""
+}
+
+// SetScoped saves a scoped override on the default registry. See Registry.SetScoped.
+func SetScoped(name, scope string, value any) error {
+	return defaultRegistry.SetScoped(name, scope, value)
+}
+
+// SetScopedContext saves a scoped override on the default registry. See Registry.SetScopedContext.
+func SetScopedContext(ctx context.Context, name, scope string, value any) error {
+	return defaultRegistry.SetScopedContext(ctx, name, scope, value)
+}
+
+// SetScoped saves value as the override of the named setting in scope, which has the form KIND:ID such as
+// tenant:acme. The value is converted and validated exactly like a global value, so the setting needs a
+// ParseFunc: settings without one only check values in their SetFunc, which overrides never reach, and
+// cannot be overridden. Overrides only change what GetScoped returns: they do not notify subscribers. They
+// are recorded in the history under name@scope.
+func (r *Registry) SetScoped(name, scope string, value any) error {
+	return r.SetScopedContext(context.Background(), name, scope, value)
+}
+
+// SetScopedContext is SetScoped with a context bounding the database transaction.
+func (r *Registry) SetScopedContext(ctx context.Context, name, scope string, value any) error {
+	return r.setScoped(ctx, scope, map[string]any{name: value}, "")
+}
+
+// setScoped saves several overrides of scope in one transaction, recording them in the history with reason.
+func (r *Registry) setScoped(ctx context.Context, scope string, values map[string]any, reason string) error {
+	if err := validateScope(scope); err != nil {
+		return err
+	}
+	store, err := r.storeContext(ctx)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	r.mu.RLock()
+	c := r.cipher
+	r.mu.RUnlock()
+	plain := make(map[string]string, len(names))
+	rows := make([]*models.AppSettingScoped, 0, len(names))
+	for _, name := range names {
+		setting, err := r.GetSetting(name)
+		if err != nil {
+			return err
+		}
+		if setting.ParseFunc == nil {
+			return fmt.Errorf("setting %s cannot be overridden per scope: it has no ParseFunc to check a value without applying it", name)
+		}
+		valueStr, err := setting.ValueToString(values[name])
+		if err != nil {
+			return fmt.Errorf("setting %s: %w", name, err)
+		}
+		if err := setting.Validate(valueStr); err != nil {
+			return setting.maskError(err)
+		}
+		stored, err := encodeValue(c, setting, scopedName(name, scope), valueStr)
+		if err != nil {
+			return err
+		}
+		plain[name] = valueStr
+		rows = append(rows, &models.AppSettingScoped{Key: name, Scope: scope, Value: stored})
+	}
+	if err := store.Transaction(func(tx *db.Store) error {
+		a := tx.AppSettingScoped
+		for _, row := range rows {
+			setting, _ := r.GetSetting(row.Key)
+			key := scopedName(row.Key, row.Scope)
+			current, err := a.Where(a.Key.Eq(row.Key), a.Scope.Eq(row.Scope)).Limit(1).Find()
+			if err != nil {
+				return err
+			}
+			old := ""
+			if len(current) > 0 {
+				if old, err = historyValue(c, setting, key, current[0].Value); err != nil {
+					return err
+				}
+			}
+			if err := a.Save(row); err != nil {
+				return err
+			}
+			if _, err := r.recordHistory(tx, key, OpSave, old, row.Value, len(current) > 0, true, reason); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.scoped == nil {
+		r.scoped = map[string]map[string]string{}
+	}
+	if r.scoped[scope] == nil {
+		r.scoped[scope] = map[string]string{}
+	}
+	for name, value := range plain {
+		r.scoped[scope][name] = value
+	}
+	return nil
+}
+
+// DeleteScoped removes a scoped override on the default registry. See Registry.DeleteScoped.
+func DeleteScoped(name, scope string) error {
+	return defaultRegistry.DeleteScoped(name, scope)
+}
+
+// DeleteScopedContext removes a scoped override on the default registry. See Registry.DeleteScopedContext.
+func DeleteScopedContext(ctx context.Context, name, scope string) error {
+	return defaultRegistry.DeleteScopedContext(ctx, name, scope)
+}
+
+// DeleteScoped removes the override of the named setting in scope, so GetScoped falls back to the next scope,
+// and records the removal in the history. Removing an override that does not exist is not an error.
+func (r *Registry) DeleteScoped(name, scope string) error {
+	return r.DeleteScopedContext(context.Background(), name, scope)
+}
+
+// DeleteScopedContext is DeleteScoped with a context bounding the database transaction.
+func (r *Registry) DeleteScopedContext(ctx context.Context, name, scope string) error {
+	return r.deleteScoped(ctx, name, scope, "")
+}
+
+// deleteScoped removes an override, recording the removal in the history with reason.
+func (r *Registry) deleteScoped(ctx context.Context, name, scope, reason string) error {
+	setting, err := r.GetSetting(name)
+	if err != nil {
+		return err
+	}
+	if err := validateScope(scope); err != nil {
+		return err
+	}
+	store, err := r.storeContext(ctx)
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	c := r.cipher
+	r.mu.RUnlock()
+	if err := store.Transaction(func(tx *db.Store) error {
+		a := tx.AppSettingScoped
+		key := scopedName(setting.Name, scope)
+		current, err := a.Where(a.Key.Eq(setting.Name), a.Scope.Eq(scope)).Limit(1).Find()
+		if err != nil || len(current) == 0 {
+			return err
+		}
+		old, err := historyValue(c, setting, key, current[0].Value)
+		if err != nil {
+			return err
+		}
+		if _, err := a.Where(a.Key.Eq(setting.Name), a.Scope.Eq(scope)).Delete(); err != nil {
+			return err
+		}
+		_, err = r.recordHistory(tx, key, OpDelete, old, "", true, false, reason)
+		return err
+	}); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	delete(r.scoped[scope], setting.Name)
+	r.mu.Unlock()
+	return nil
+}
+
+// GetScoped returns the value in the first of scopes that overrides the setting, and Get otherwise. See
+// Registry.GetScoped.
+func (v *Value[T]) GetScoped(scopes ...string) (T, error) {
+	setting, err := v.registry.GetSetting(v.name)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	for _, scope := range scopes {
+		if err := validateScope(scope); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+	value, scope := v.registry.resolveScoped(setting, scopes)
+	if scope == "" {
+		return v.Get(), nil
+	}
+	return v.codec.Parse(value)
+}
+
+// runScoped lists the value every visible setting resolves to in c.Scope and the scope or source it came
+// from.
+func (c *SettingsListActiveCommand) runScoped(r *Registry) error {
+	for _, scope := range c.Scope {
+		if err := validateScope(scope); err != nil {
+			return printAndReturnErr(err)
+		}
+	}
+	resolved := []*models.AppSetting{}
+	for _, s := range r.snapshot() {
+		if s.Hidden {
+			continue
+		}
+		value, scope := r.resolveScoped(s, c.Scope)
+		source := "scope " + scope
+		if scope == "" {
+			source = string(r.source(s))
+		}
+		resolved = append(resolved, &models.AppSetting{
+			Key:         s.Name,
+			Value:       s.display(value),
+			Description: s.describe(),
+			Source:      source,
+		})
+	}
+	printSettings(resolved)
+	return nil
+}
+
+// runScoped saves the overrides of c.Scope given as <setting> <value> or NAME=VALUE pairs.
+func (c *SettingsSaveCommand) runScoped(ctx context.Context, r *Registry) error {
+	if c.IfVersion != nil {
+		return printAndReturnErr(errors.New("--if-version does not apply to scoped values"))
+	}
+	values := map[string]any{c.Setting: c.Value}
+	names := []string{c.Setting}
+	if strings.Contains(c.Setting, "=") {
+		var err error
+		if values, names, err = c.pairs(r); err != nil {
+			return printAndReturnErr(err)
+		}
+	} else if len(c.Pairs) > 0 {
+		return printAndReturnErr(errors.New("use either <setting> <value> or NAME=VALUE pairs"))
+	} else if _, err := r.getCLISetting(c.Setting); err != nil {
+		return printAndReturnErr(err)
+	}
+	if err := r.setScoped(ctx, c.Scope, values, c.Reason); err != nil {
+		return printAndReturnErr(err)
+	}
+	for _, name := range names {
+		setting, _ := r.GetSetting(name)
+		fmt.Printf("Setting %s saved to %s in scope %s\n", name, setting.display(values[name].(string)), c.Scope)
+	}
+	return r.applyToDaemon(ctx, names...)
+}
@@ -0,0 +1,231 @@
+package app_settings
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+)
+
+func TestScoped_FallbackChain(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	limit := 10
+	r.RegisterIntSetting("rate.limit", "Requests per second", &limit, Between(1, 1000))
+	timeout := RegisterIn(r, "timeout", "Timeout", 5*time.Second, DurationCodec)
+	path := tempDBPath(t)
+	if err := r.Setup(path, SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	if err := r.SetScoped("rate.limit", "tenant:acme", 50); err != nil {
+		t.Fatalf("SetScoped tenant failed: %v", err)
+	}
+	if err := r.SetScoped("rate.limit", "user:bob", 70); err != nil {
+		t.Fatalf("SetScoped user failed: %v", err)
+	}
+	if err := r.SetSetting("rate.limit", 20); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	for _, tc := range []struct {
+		scopes []string
+		want   string
+	}{
+		{[]string{"user:bob", "tenant:acme"}, "70"},
+		{[]string{"user:alice", "tenant:acme"}, "50"},
+		{[]string{"user:alice", "tenant:other"}, "20"},
+		{nil, "20"},
+	} {
+		if got, err := r.GetScoped("rate.limit", tc.scopes...); err != nil || got != tc.want {
+			t.Fatalf("GetScoped(%v) = %q, %v; want %q", tc.scopes, got, err, tc.want)
+		}
+	}
+	if limit != 20 {
+		t.Fatalf("scoped overrides changed the global value to %d", limit)
+	}
+
+	if err := r.SetScoped("rate.limit", "tenant:acme", 5000); err == nil {
+		t.Fatalf("expected the global validator to reject a scoped value")
+	}
+	if err := r.SetScoped("timeout", "tenant:acme", "soon"); err == nil {
+		t.Fatalf("expected an unparseable scoped value to fail")
+	}
+	if err := r.SetScoped("rate.limit", "acme", 5); err == nil {
+		t.Fatalf("expected a scope without a kind to fail")
+	}
+	if err := r.SetScoped("timeout", "tenant:acme", 30*time.Second); err != nil {
+		t.Fatalf("SetScoped typed failed: %v", err)
+	}
+	if got, err := timeout.GetScoped("tenant:acme"); err != nil || got != 30*time.Second {
+		t.Fatalf("Value.GetScoped = %v, %v", got, err)
+	}
+	if got, _ := timeout.GetScoped("tenant:other"); got != 5*time.Second {
+		t.Fatalf("Value.GetScoped without an override = %v", got)
+	}
+
+	if err := r.DeleteScoped("rate.limit", "user:bob"); err != nil {
+		t.Fatalf("DeleteScoped failed: %v", err)
+	}
+	if got, _ := r.GetScoped("rate.limit", "user:bob", "tenant:acme"); got != "50" {
+		t.Fatalf("deleted override still resolves: %q", got)
+	}
+	entries, err := r.History("rate.limit", time.Time{})
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	ops := []string{}
+	for _, e := range entries {
+		ops = append(ops, e.Key+" "+e.Operation+" "+e.OldValue+">"+e.NewValue)
+	}
+	want := "rate.limit@tenant:acme save >50|rate.limit@user:bob save >70|rate.limit save >20|rate.limit@user:bob delete 70>"
+	if got := strings.Join(ops, "|"); got != want {
+		t.Fatalf("scoped changes not recorded in the history:\n got %s\nwant %s", got, want)
+	}
+
+	var mode string
+	r.RegisterSetting(&Setting{Name: "mode", GetFunc: func() string { return mode }, SetFunc: func(s string) error { mode = s; return nil }})
+	if err := r.SetScoped("mode", "tenant:acme", "fast"); err == nil {
+		t.Fatalf("expected a setting without a ParseFunc to refuse scoped values")
+	}
+
+	// A second instance loads the overrides from the database.
+	other := NewRegistry()
+	otherLimit := 10
+	other.RegisterIntSetting("rate.limit", "Requests per second", &otherLimit)
+	if err := other.Setup(path, SettingsOptions{}); err != nil {
+		t.Fatalf("second setup failed: %v", err)
+	}
+	if got, _ := other.GetScoped("rate.limit", "user:bob", "tenant:acme"); got != "50" {
+		t.Fatalf("overrides not loaded from the database: %q", got)
+	}
+}
+
+func TestScoped_EncryptedSettings(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var token string
+	r.RegisterStringSetting("api.token", "API token", &token, Encrypted())
+	path := tempDBPath(t)
+	key := make([]byte, 32)
+	if err := r.Setup(path, SettingsOptions{Encryption: &EncryptionOptions{Key: StaticKey(key)}}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.SetScoped("api.token", "tenant:acme", "acme-secret"); err != nil {
+		t.Fatalf("SetScoped failed: %v", err)
+	}
+	rows, err := r.store.AppSettingScoped.Find()
+	if err != nil || len(rows) != 1 || strings.Contains(rows[0].Value, "acme-secret") {
+		t.Fatalf("scoped value of an encrypted setting stored in clear text: %v %v", rows, err)
+	}
+	newKey := make([]byte, 32)
+	newKey[0] = 1
+	if err := r.Rekey(StaticKey(newKey)); err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+	if err := r.RetrieveAppSettings(); err != nil {
+		t.Fatalf("reload after rekey failed: %v", err)
+	}
+	if got, _ := r.GetScoped("api.token", "tenant:acme"); got != "acme-secret" {
+		t.Fatalf("scoped value lost after rekey: %q", got)
+	}
+	stored, err := r.store.AppSettingHistory.Find()
+	if err != nil || len(stored) != 1 || !strings.HasPrefix(stored[0].NewValue, encryptedPrefix) {
+		t.Fatalf("scoped value of an encrypted setting stored in clear text in the history: %v %v", stored, err)
+	}
+	if entries, err := r.History("api.token", time.Time{}); err != nil || len(entries) != 1 || entries[0].NewValue != "acme-secret" {
+		t.Fatalf("scoped history not decrypted after rekey: %v %v", entries, err)
+	}
+}
+
+func TestSettingsCommand_Scope(t *testing.T) {
+	r := NewRegistry()
+	limit := 10
+	r.RegisterIntSetting("rate.limit", "Requests per second", &limit, Between(1, 1000))
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	var cli struct {
+		Settings SettingsCommand `cmd:""`
+	}
+	cli.Settings.Registry = r
+	parser, err := kong.New(&cli, kong.Exit(func(int) {}))
+	if err != nil {
+		t.Fatalf("kong.New failed: %v", err)
+	}
+	run := func(args ...string) (string, error) {
+		ctx, err := parser.Parse(args)
+		if err != nil {
+			return "", err
+		}
+		var runErr error
+		out := captureStdout(func() { runErr = ctx.Run() })
+		return out, runErr
+	}
+
+	if out, err := run("settings", "save", "rate.limit", "50", "--scope", "tenant:acme", "--reason", "acme launch"); err != nil || !strings.Contains(out, "in scope tenant:acme") {
+		t.Fatalf("save --scope failed: %v\n%s", err, out)
+	}
+	if _, err := run("settings", "save", "rate.limit", "5000", "--scope", "tenant:acme"); err == nil {
+		t.Fatalf("expected save --scope to apply the global validator")
+	}
+	if limit != 10 {
+		t.Fatalf("save --scope changed the global value to %d", limit)
+	}
+	out, err := run("settings", "list", "active", "--scope", "user:bob,tenant:acme")
+	if err != nil || !strings.Contains(out, "50") || !strings.Contains(out, "scope tenant:acme") {
+		t.Fatalf("list active --scope failed: %v\n%s", err, out)
+	}
+	if _, err := run("settings", "list"); err == nil || strings.Contains(err.Error(), "--scope") || !strings.Contains(err.Error(), "active") {
+		t.Fatalf("expected bare list to name its subcommands, got %v", err)
+	}
+	if _, err := run("settings", "list", "saved"); err != nil {
+		t.Fatalf("list saved no longer parses: %v", err)
+	}
+	if _, err := run("settings", "remove", "rate.limit", "--scope", "tenant:acme"); err != nil {
+		t.Fatalf("remove --scope failed: %v", err)
+	}
+	if got, _ := r.GetScoped("rate.limit", "tenant:acme"); got != "10" {
+		t.Fatalf("remove --scope left the override: %q", got)
+	}
+	entries, _ := r.History("rate.limit", time.Time{})
+	if len(entries) != 2 || entries[0].Reason != "acme launch" || entries[1].Operation != OpDelete {
+		t.Fatalf("scoped CLI changes not recorded with their reason: %#v", entries)
+	}
+	if out, err := run("settings", "history", "rate.limit"); err != nil || !strings.Contains(out, "rate.limit@tenant:acme") {
+		t.Fatalf("history does not list scoped changes: %v\n%s", err, out)
+	}
+}
+
+func TestScoped_DBWatchPicksUpOtherInstances(t *testing.T) {
+	t.Parallel()
+	path := tempDBPath(t)
+	watcher := NewRegistry()
+	region := "eu"
+	watcher.RegisterStringSetting("region", "Region", &region)
+	if err := watcher.Setup(path, SettingsOptions{DBWatchInterval: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	defer watcher.Close()
+
+	writer := NewRegistry()
+	otherRegion := "eu"
+	writer.RegisterStringSetting("region", "Region", &otherRegion)
+	if err := writer.Setup(path, SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := writer.SetScoped("region", "tenant:acme", "us"); err != nil {
+		t.Fatalf("SetScoped failed: %v", err)
+	}
+	waitFor(t, "the scoped override", func() bool {
+		got, _ := watcher.GetScoped("region", "tenant:acme")
+		return got == "us"
+	})
+	if err := writer.DeleteScoped("region", "tenant:acme"); err != nil {
+		t.Fatalf("DeleteScoped failed: %v", err)
+	}
+	waitFor(t, "the override to be removed", func() bool {
+		got, _ := watcher.GetScoped("region", "tenant:acme")
+		return got == "eu"
+	})
+}
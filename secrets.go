@@ -0,0 +1,63 @@
+package app_settings
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maskedValue replaces the value of a sensitive setting in output.
+const maskedValue = "********"
+
+// errSensitiveValue stands in for error details that could contain a sensitive value.
+var errSensitiveValue = errors.New("invalid value (details hidden for sensitive setting)")
+
+// Sensitive marks a setting as secret so its value is masked in every output path.
+func Sensitive() Option {
+	return func(s *Setting) {
+		s.Sensitive = true
+	}
+}
+
+// RegisterSecretSetting is Registry.RegisterSecretSetting on the default registry.
+func RegisterSecretSetting(name, description string, prop *string, opts ...Option) {
+	defaultRegistry.RegisterSecretSetting(name, description, prop, opts...)
+}
+
+// RegisterSecretSetting registers a sensitive string setting such as an API key or password.
+func (r *Registry) RegisterSecretSetting(name, description string, prop *string, opts ...Option) {
+	registerPointer(r, name, description, prop, StringCodec, append([]Option{Sensitive()}, opts...)...)
+}
+
+// display returns value as it may be shown to a user: masked for sensitive settings unless empty.
+func (s *Setting) display(value string) string {
+	if s.Sensitive && value != "" {
+		return maskedValue
+	}
+	return value
+}
+
+// maskError drops error details that may echo a sensitive setting's value, keeping the broken rule.
+func (s *Setting) maskError(err error) error {
+	if !s.Sensitive || err == nil {
+		return err
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return &ValidationError{Setting: verr.Setting, Rule: verr.Rule, Err: errSensitiveValue}
+	}
+	return fmt.Errorf("setting %s: %w", s.Name, errSensitiveValue)
+}
+
+// Run prints the running value of a setting. Sensitive values are masked unless --reveal is given.
+func (c *SettingsGetCommand) Run(r *Registry) error {
+	setting, err := r.getCLISetting(c.Setting)
+	if err != nil {
+		return printAndReturnErr(err)
+	}
+	value := setting.GetFunc()
+	if !c.Reveal {
+		value = setting.display(value)
+	}
+	fmt.Println(value)
+	return nil
+}
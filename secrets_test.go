@@ -0,0 +1,68 @@
+package app_settings
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dan-sherwin/go-app-settings/db/models"
+)
+
+func TestSecretSettings_AreMaskedEverywhere(t *testing.T) {
+	r := NewRegistry()
+	apiKey := "default-key"
+	pin := 1234
+	r.RegisterSecretSetting("api.key", "API key", &apiKey, MinLength(8))
+	r.RegisterIntSetting("pin", "PIN", &pin, Sensitive())
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	out := captureStdout(func() {
+		if err := (&SettingsSaveCommand{Setting: "api.key", Value: "sk-live-secret"}).Run(r); err != nil {
+			t.Errorf("save failed: %v", err)
+		}
+		_ = (&SettingsListDefaultsCommand{}).Run(r)
+		_ = (&SettingsListSavedCommand{}).Run(r)
+		_ = (&SettingsListActiveCommand{}).Run(r)
+		_ = (&SettingsGetCommand{Setting: "api.key"}).Run(r)
+	})
+	if strings.Contains(out, "sk-live-secret") || strings.Contains(out, "default-key") {
+		t.Fatalf("secret leaked in CLI output: %s", out)
+	}
+	if !strings.Contains(out, maskedValue) {
+		t.Fatalf("expected masked value in output: %s", out)
+	}
+
+	running := []models.AppSetting{}
+	if err := (&settingsService{registry: r}).GetRunningSettings(&struct{}{}, &running); err != nil {
+		t.Fatalf("GetRunningSettings failed: %v", err)
+	}
+	for _, s := range running {
+		if s.Key == "api.key" && s.Value != maskedValue {
+			t.Fatalf("RPC reply leaked secret: %#v", s)
+		}
+	}
+	if got := r.SettingsVars()["api.key"]; got != maskedValue {
+		t.Fatalf("Kong vars leaked secret: %q", got)
+	}
+
+	for _, tc := range []struct{ setting, value string }{{"api.key", "short"}, {"pin", "not-a-pin"}} {
+		err := r.SetSetting(tc.setting, tc.value)
+		if err == nil || strings.Contains(err.Error(), tc.value) {
+			t.Fatalf("expected error without the rejected value, got %v", err)
+		}
+	}
+	if !strings.Contains(r.SetSetting("api.key", "short").Error(), "minlength=8") {
+		t.Fatalf("masked validation error should still name the rule")
+	}
+
+	out = captureStdout(func() {
+		_ = (&SettingsGetCommand{Setting: "api.key", Reveal: true}).Run(r)
+	})
+	if strings.TrimSpace(out) != "sk-live-secret" {
+		t.Fatalf("expected --reveal to show the value, got %q", out)
+	}
+	if apiKey != "sk-live-secret" {
+		t.Fatalf("in-memory value should be the real secret, got %q", apiKey)
+	}
+}
@@ -0,0 +1,246 @@
+package app_settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RegisterStruct registers every field of the struct cfg points to on the default registry. See Registry.RegisterStruct.
+func RegisterStruct(prefix string, cfg any) error {
+	return defaultRegistry.RegisterStruct(prefix, cfg)
+}
+
+// RegisterStruct walks the struct cfg points to and registers one setting per field, bound to the field.
+// The current field values become the defaults. Fields are configured with struct tags:
+//
+//	setting:"name"    setting name (default: the field name in snake_case); "-" skips the field
+//	desc:"..."        setting description
+//	hidden:"true"     hide the setting from the settings CLI
+//	sensitive:"true"  mask the value in all output
+//	enum:"a,b,c"      only accept one of the listed values
+//	codec:"json"      store the field as JSON; codec:"cron" validates a string as a cron expression
+//
+// Nested structs are registered with dotted names ("prefix.nested.field"); embedded structs without a
+// setting tag are flattened into their parent. Every field type supported by the Register*Setting helpers
+// is accepted, including named types whose underlying type is one of them. An unsupported field type is
+// reported as an error and nothing is registered.
+func (r *Registry) RegisterStruct(prefix string, cfg any) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("RegisterStruct requires a non-nil pointer to a struct, got %T", cfg)
+	}
+	settings, err := structSettings(prefix, v.Elem())
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		r.RegisterSetting(s)
+	}
+	return nil
+}
+
+func structSettings(prefix string, v reflect.Value) ([]*Setting, error) {
+	settings := []*Setting{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, tagged := field.Tag.Lookup("setting")
+		if tag == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		name := tag
+		if name == "" {
+			name = snakeCase(field.Name)
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if isNestedStruct(field.Type) && field.Tag.Get("codec") == "" {
+			nestedPrefix := name
+			if field.Anonymous && !tagged {
+				nestedPrefix = prefix
+			}
+			nested, err := structSettings(nestedPrefix, fv)
+			if err != nil {
+				return nil, err
+			}
+			settings = append(settings, nested...)
+			continue
+		}
+		s, err := fieldSetting(name, field.Tag.Get("desc"), field.Tag.Get("codec"), fv.Addr())
+		if err != nil {
+			return nil, fmt.Errorf("field %s (%s): %w", field.Name, field.Type, err)
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			applyOptions(s, []Option{OneOf(strings.Split(enum, ",")...)})
+		}
+		if field.Tag.Get("hidden") != "" {
+			if s.Hidden, err = strconv.ParseBool(field.Tag.Get("hidden")); err != nil {
+				return nil, fmt.Errorf("field %s: invalid hidden tag: %w", field.Name, err)
+			}
+		}
+		if field.Tag.Get("sensitive") != "" {
+			if s.Sensitive, err = strconv.ParseBool(field.Tag.Get("sensitive")); err != nil {
+				return nil, fmt.Errorf("field %s: invalid sensitive tag: %w", field.Name, err)
+			}
+		}
+		settings = append(settings, s)
+	}
+	return settings, nil
+}
+
+var leafStructTypes = []reflect.Type{
+	reflect.TypeFor[time.Time](),
+	reflect.TypeFor[net.IPNet](),
+	reflect.TypeFor[url.URL](),
+}
+
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for _, leaf := range leafStructTypes {
+		if t == leaf {
+			return false
+		}
+	}
+	return true
+}
+
+// basicKindTypes maps the kinds the codecs handle to the types they are written for, so named types
+// such as `type Level string` can be converted to a pointer the codecs understand.
+var basicKindTypes = map[reflect.Kind]reflect.Type{
+	reflect.String:  reflect.TypeFor[string](),
+	reflect.Bool:    reflect.TypeFor[bool](),
+	reflect.Int:     reflect.TypeFor[int](),
+	reflect.Int8:    reflect.TypeFor[int8](),
+	reflect.Int16:   reflect.TypeFor[int16](),
+	reflect.Int32:   reflect.TypeFor[int32](),
+	reflect.Int64:   reflect.TypeFor[int64](),
+	reflect.Uint:    reflect.TypeFor[uint](),
+	reflect.Uint8:   reflect.TypeFor[uint8](),
+	reflect.Uint16:  reflect.TypeFor[uint16](),
+	reflect.Uint32:  reflect.TypeFor[uint32](),
+	reflect.Uint64:  reflect.TypeFor[uint64](),
+	reflect.Float32: reflect.TypeFor[float32](),
+	reflect.Float64: reflect.TypeFor[float64](),
+}
+
+// fieldSetting builds a setting bound to the field ptr points to, choosing the codec from the field type.
+func fieldSetting(name, description, codec string, ptr reflect.Value) (*Setting, error) {
+	switch codec {
+	case "":
+	case "json":
+		return jsonFieldSetting(name, description, ptr), nil
+	case "cron":
+		p, ok := ptr.Interface().(*string)
+		if !ok {
+			return nil, fmt.Errorf("codec cron requires a string field")
+		}
+		return newPointerSetting(name, description, p, CronCodec), nil
+	default:
+		return nil, fmt.Errorf("unknown codec %q", codec)
+	}
+	if _, exact := ptr.Interface().(*time.Duration); !exact {
+		if base, ok := basicKindTypes[ptr.Elem().Kind()]; ok {
+			ptr = ptr.Convert(reflect.PointerTo(base))
+		}
+	}
+	switch p := ptr.Interface().(type) {
+	case *string:
+		return newPointerSetting(name, description, p, StringCodec), nil
+	case *bool:
+		return newPointerSetting(name, description, p, BoolCodec), nil
+	case *int:
+		return newPointerSetting(name, description, p, IntCodec), nil
+	case *int8:
+		return newPointerSetting(name, description, p, Int8Codec), nil
+	case *int16:
+		return newPointerSetting(name, description, p, Int16Codec), nil
+	case *int32:
+		return newPointerSetting(name, description, p, Int32Codec), nil
+	case *int64:
+		return newPointerSetting(name, description, p, Int64Codec), nil
+	case *uint:
+		return newPointerSetting(name, description, p, UintCodec), nil
+	case *uint8:
+		return newPointerSetting(name, description, p, Uint8Codec), nil
+	case *uint16:
+		return newPointerSetting(name, description, p, Uint16Codec), nil
+	case *uint32:
+		return newPointerSetting(name, description, p, Uint32Codec), nil
+	case *uint64:
+		return newPointerSetting(name, description, p, Uint64Codec), nil
+	case *float32:
+		return newPointerSetting(name, description, p, Float32Codec), nil
+	case *float64:
+		return newPointerSetting(name, description, p, Float64Codec), nil
+	case *time.Duration:
+		return newPointerSetting(name, description, p, DurationCodec), nil
+	case *time.Time:
+		return newPointerSetting(name, description, p, TimeCodec), nil
+	case *[]string:
+		return newPointerSetting(name, description, p, StringSliceCodec), nil
+	case *net.IP:
+		return newPointerSetting(name, description, p, IPCodec), nil
+	case *net.IPNet:
+		return newPointerSetting(name, description, p, IPNetCodec), nil
+	case *url.URL:
+		return newPointerSetting(name, description, p, URLCodec), nil
+	}
+	return nil, fmt.Errorf("unsupported setting type; use codec:\"json\" or register it with a custom codec")
+}
+
+// jsonFieldSetting is RegisterJSONSetting for a field whose type is only known through reflection.
+func jsonFieldSetting(name, description string, ptr reflect.Value) *Setting {
+	return &Setting{
+		Name:              name,
+		Description:       description,
+		ValueToStringFunc: jsonValueToString,
+		ParseFunc: func(s string) error {
+			return json.Unmarshal([]byte(s), reflect.New(ptr.Type().Elem()).Interface())
+		},
+		GetFunc: func() string {
+			b, err := json.Marshal(ptr.Interface())
+			if err != nil {
+				return ""
+			}
+			return string(b)
+		},
+		SetFunc: func(s string) error {
+			value := reflect.New(ptr.Type().Elem())
+			if err := json.Unmarshal([]byte(s), value.Interface()); err != nil {
+				return err
+			}
+			ptr.Elem().Set(value.Elem())
+			return nil
+		},
+	}
+}
+
+// snakeCase converts a Go field name such as HTTPPort to http_port.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, c := range runes {
+		if unicode.IsUpper(c) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
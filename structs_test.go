@@ -0,0 +1,105 @@
+package app_settings
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type testLogLevel string
+
+type testServerConfig struct {
+	HTTPPort int           `desc:"HTTP port"`
+	Timeout  time.Duration `setting:"timeout" desc:"Request timeout"`
+	Level    testLogLevel  `setting:"level" enum:"debug,info"`
+	Token    string        `hidden:"true"`
+	Ignored  string        `setting:"-"`
+	Schedule string        `codec:"cron"`
+	DB       struct {
+		Hosts []string `desc:"Database hosts"`
+		Pool  struct {
+			Size uint16
+		}
+	}
+	Limits  map[string]int `codec:"json"`
+	private int
+}
+
+func TestRegisterStruct_RegistersEveryField(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	cfg := testServerConfig{HTTPPort: 8080, Timeout: time.Second, Level: "info", Schedule: "@daily"}
+	cfg.DB.Pool.Size = 4
+	if err := r.RegisterStruct("server", &cfg); err != nil {
+		t.Fatalf("RegisterStruct failed: %v", err)
+	}
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	vars := r.SettingsVars()
+	want := map[string]string{
+		"server.http_port":    "8080",
+		"server.timeout":      "1s",
+		"server.level":        "info",
+		"server.token":        "",
+		"server.schedule":     "@daily",
+		"server.db.hosts":     "",
+		"server.db.pool.size": "4",
+		"server.limits":       "null",
+	}
+	if len(vars) != len(want) {
+		t.Fatalf("unexpected registered settings: %#v", vars)
+	}
+	for name, value := range want {
+		if got, ok := vars[name]; !ok || got != value {
+			t.Fatalf("setting %s: expected %q, got %q (registered=%v)", name, value, got, ok)
+		}
+	}
+	if s, _ := r.GetSetting("server.token"); !s.Hidden {
+		t.Fatalf("expected server.token to be hidden")
+	}
+	if s, _ := r.GetSetting("server.http_port"); s.Description != "HTTP port" {
+		t.Fatalf("unexpected description %q", s.Description)
+	}
+
+	for name, value := range map[string]string{
+		"server.http_port":    "9000",
+		"server.level":        "debug",
+		"server.db.hosts":     "a,b",
+		"server.db.pool.size": "16",
+		"server.limits":       `{"rps":10}`,
+	} {
+		if err := r.SetSetting(name, value); err != nil {
+			t.Fatalf("SetSetting %s failed: %v", name, err)
+		}
+	}
+	if cfg.HTTPPort != 9000 || cfg.Level != "debug" || strings.Join(cfg.DB.Hosts, "|") != "a|b" || cfg.DB.Pool.Size != 16 || cfg.Limits["rps"] != 10 {
+		t.Fatalf("struct fields not updated: %#v", cfg)
+	}
+	if err := r.SetSetting("server.level", "trace"); err == nil {
+		t.Fatalf("expected enum validation error")
+	}
+	if err := r.SetSetting("server.schedule", "every tuesday"); err == nil {
+		t.Fatalf("expected cron validation error")
+	}
+}
+
+func TestRegisterStruct_RejectsUnsupportedTypes(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	cfg := struct {
+		Name    string
+		Handler func()
+	}{}
+	err := r.RegisterStruct("", &cfg)
+	if err == nil || !strings.Contains(err.Error(), "Handler") {
+		t.Fatalf("expected unsupported field error naming Handler, got %v", err)
+	}
+	if len(r.snapshot()) != 0 {
+		t.Fatalf("nothing should be registered when a field is unsupported")
+	}
+	if err := r.RegisterStruct("", cfg); err == nil {
+		t.Fatalf("expected error for non-pointer cfg")
+	}
+}
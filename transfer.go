@@ -0,0 +1,305 @@
+package app_settings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ExportOptions selects what Export writes.
+type ExportOptions struct {
+	// Format is "json" (the default), "env" or "properties". Files in every format can be read back by Import.
+	Format string
+	// IncludeDefaults adds the default value of settings that have no saved value.
+	IncludeDefaults bool
+	// IncludeHidden adds settings registered with Hidden.
+	IncludeHidden bool
+	// IncludeSensitive adds the clear-text values of sensitive settings, which are skipped otherwise.
+	IncludeSensitive bool
+}
+
+// Export writes the saved settings of the default registry. See Registry.Export.
+func Export(w io.Writer, opts ExportOptions) error {
+	return defaultRegistry.Export(w, opts)
+}
+
+// ExportContext writes the saved settings of the default registry. See Registry.ExportContext.
+func ExportContext(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	return defaultRegistry.ExportContext(ctx, w, opts)
+}
+
+// Export writes the saved settings to w in the format of a config file, keyed by setting name.
+func (r *Registry) Export(w io.Writer, opts ExportOptions) error {
+	return r.ExportContext(context.Background(), w, opts)
+}
+
+// ExportContext is Export with a context bounding the database query.
+func (r *Registry) ExportContext(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	values, _, err := r.exportValues(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return writeSettingsFile(w, opts.Format, values)
+}
+
+// exportValues returns the values Export writes and the names of the sensitive settings it skipped.
+func (r *Registry) exportValues(ctx context.Context, opts ExportOptions) (map[string]string, []string, error) {
+	store, err := r.storeContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	saved, err := r.savedStates(store)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := map[string]string{}
+	skipped := []string{}
+	for _, s := range r.snapshot() {
+		if s.Hidden && !opts.IncludeHidden {
+			continue
+		}
+		state := saved[s.Name]
+		if !state.saved && opts.IncludeDefaults {
+			state.value, state.saved = r.defaultValue(s.Name)
+		}
+		if !state.saved {
+			continue
+		}
+		if s.Sensitive && !opts.IncludeSensitive {
+			skipped = append(skipped, s.Name)
+			continue
+		}
+		values[s.Name] = state.value
+	}
+	return values, skipped, nil
+}
+
+// writeSettingsFile writes values as a JSON object, a dotenv file or a properties file, sorted by name.
+func writeSettingsFile(w io.Writer, format string, values map[string]string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	switch format {
+	case "", "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(values)
+	case "env", "dotenv":
+		for _, name := range names {
+			value, err := dotenvValue(values[name])
+			if err != nil {
+				return fmt.Errorf("export %s: %w", name, err)
+			}
+			if _, err := fmt.Fprintf(w, "%s=%s\n", name, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "properties":
+		for _, name := range names {
+			if _, err := fmt.Fprintf(w, "%s=%s\n", propertyKeyEscapes.Replace(name), propertyValue(values[name])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown export format %q: use json, env or properties", format)
+}
+
+// dotenvValue quotes a value so readDotenv reads it back unchanged.
+func dotenvValue(value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", errors.New("values spanning several lines cannot be written to a dotenv file; use json or properties")
+	}
+	switch {
+	case value == strings.TrimSpace(value) && !strings.ContainsAny(value, " \t#'\""):
+		return value, nil
+	case !strings.Contains(value, "'"):
+		return "'" + value + "'", nil
+	case !strings.Contains(value, `"`):
+		return `"` + value + `"`, nil
+	}
+	return "", errors.New("values containing both quote characters cannot be written to a dotenv file; use json or properties")
+}
+
+var (
+	propertyKeyEscapes   = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\t", `\t`, "\r", `\r`, "=", `\=`, ":", `\:`, " ", `\ `, "#", `\#`, "!", `\!`)
+	propertyValueEscapes = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+)
+
+// propertyValue escapes a value so readProperties reads it back unchanged.
+func propertyValue(value string) string {
+	value = propertyValueEscapes.Replace(value)
+	if strings.HasPrefix(value, " ") {
+		value = `\` + value
+	}
+	return value
+}
+
+// ImportOptions controls Import.
+type ImportOptions struct {
+	// Replace removes the saved values of visible settings missing from the file. By default they are kept.
+	// Sensitive settings are always kept, since Export leaves them out unless IncludeSensitive is set.
+	Replace bool
+	// DryRun computes the changes without saving anything.
+	DryRun bool
+	// Reason is recorded in the settings history.
+	Reason string
+}
+
+// ImportChange is a change Import makes to a saved value.
+type ImportChange struct {
+	Name string
+	// Old is the saved value before the import; Added reports that there was none.
+	Old   string
+	Added bool
+	// New is the imported value; Removed reports that the saved value is removed instead.
+	New     string
+	Removed bool
+}
+
+// Import imports a settings file into the default registry. See Registry.Import.
+func Import(name string, opts ImportOptions) ([]ImportChange, error) {
+	return defaultRegistry.Import(name, opts)
+}
+
+// ImportContext imports a settings file into the default registry. See Registry.ImportContext.
+func ImportContext(ctx context.Context, name string, opts ImportOptions) ([]ImportChange, error) {
+	return defaultRegistry.ImportContext(ctx, name, opts)
+}
+
+// Import saves the settings of a JSON, dotenv or properties file, keyed by setting name, and returns the
+// changes to the saved values. Every value is validated before anything is written and all rows are written
+// in one transaction; a value SetFunc then rejects undoes the import, so either the whole file is imported or
+// the previous saved values are written back. Settings without a ParseFunc are refused, dry runs included,
+// since only their SetFunc could check a value and it runs after the rows are written.
+func (r *Registry) Import(name string, opts ImportOptions) ([]ImportChange, error) {
+	return r.ImportContext(context.Background(), name, opts)
+}
+
+// ImportContext is Import with a context bounding the database transaction and the SetFuncs.
+func (r *Registry) ImportContext(ctx context.Context, name string, opts ImportOptions) ([]ImportChange, error) {
+	values, err := readConfigFile(name)
+	if err != nil {
+		return nil, err
+	}
+	store, err := r.storeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, err := r.savedStates(store)
+	if err != nil {
+		return nil, err
+	}
+	plan := []savedChange{}
+	changes := []ImportChange{}
+	for _, s := range r.snapshot() {
+		value, inFile := values[s.Name]
+		old := current[s.Name]
+		switch {
+		case inFile && old.saved && old.value == value:
+			continue
+		case inFile:
+			if err := r.checkNotOverridden(s); err != nil {
+				return nil, err
+			}
+			if s.ParseFunc == nil {
+				return nil, fmt.Errorf("setting %s cannot be imported: it has no ParseFunc to check a value before it is saved", s.Name)
+			}
+			if err := s.Validate(value); err != nil {
+				return nil, s.maskError(err)
+			}
+			plan = append(plan, savedChange{setting: s, state: savedState{value: value, saved: true}})
+			changes = append(changes, ImportChange{Name: s.Name, Old: old.value, Added: !old.saved, New: value})
+		case opts.Replace && old.saved && !s.Hidden && !s.Sensitive:
+			if err := r.checkNotOverridden(s); err != nil {
+				return nil, err
+			}
+			plan = append(plan, savedChange{setting: s})
+			changes = append(changes, ImportChange{Name: s.Name, Old: old.value, Removed: true})
+		}
+	}
+	for key := range values {
+		if _, err := r.GetSetting(key); err != nil {
+			return nil, fmt.Errorf("import %s: unknown setting %s", name, key)
+		}
+	}
+	if opts.DryRun {
+		return changes, nil
+	}
+	reason := opts.Reason
+	if reason == "" {
+		reason = "import " + name
+	}
+	if err := r.commitSaved(ctx, store, plan, OpImport, reason); err != nil {
+		return nil, fmt.Errorf("import %s failed: %w", name, err)
+	}
+	return changes, nil
+}
+
+// Run prints the saved settings in the chosen format.
+func (c *SettingsExportCommand) Run(r *Registry) error {
+	values, skipped, err := r.exportValues(context.Background(), ExportOptions{
+		Format:           c.Format,
+		IncludeDefaults:  c.IncludeDefaults,
+		IncludeHidden:    c.IncludeHidden,
+		IncludeSensitive: c.Reveal,
+	})
+	if err != nil {
+		return printAndReturnErr(err)
+	}
+	if err := writeSettingsFile(os.Stdout, c.Format, values); err != nil {
+		return printAndReturnErr(err)
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped sensitive settings %s; use --reveal to export them\n", strings.Join(skipped, ", "))
+	}
+	return nil
+}
+
+// Run imports a settings file, or with --dry-run prints the changes it would make.
+func (c *SettingsImportCommand) Run(r *Registry) error {
+	ctx := context.Background()
+	changes, err := r.ImportContext(ctx, c.File, ImportOptions{Replace: c.Replace, DryRun: c.DryRun, Reason: c.Reason})
+	if err != nil {
+		return printAndReturnErr(err)
+	}
+	if len(changes) == 0 {
+		fmt.Println("No changes to import")
+		return nil
+	}
+	slices.SortFunc(changes, func(a, b ImportChange) int { return strings.Compare(a.Name, b.Name) })
+	for _, ch := range changes {
+		setting, err := r.GetSetting(ch.Name)
+		if err != nil {
+			return printAndReturnErr(err)
+		}
+		switch {
+		case ch.Removed:
+			fmt.Printf("- %s = %s\n", ch.Name, setting.display(ch.Old))
+		case ch.Added:
+			fmt.Printf("+ %s = %s\n", ch.Name, setting.display(ch.New))
+		default:
+			fmt.Printf("~ %s: %s -> %s\n", ch.Name, setting.display(ch.Old), setting.display(ch.New))
+		}
+	}
+	if c.DryRun {
+		fmt.Printf("Dry run: %d changes, nothing saved\n", len(changes))
+		return nil
+	}
+	fmt.Printf("Imported %d changes from %s\n", len(changes), c.File)
+	names := make([]string, 0, len(changes))
+	for _, ch := range changes {
+		names = append(names, ch.Name)
+	}
+	return r.applyToDaemon(ctx, names...)
+}
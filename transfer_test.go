@@ -0,0 +1,227 @@
+package app_settings
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImport_RoundTripsEveryFormat(t *testing.T) {
+	t.Parallel()
+	values := map[string]string{
+		"banner": " hello: world # not a comment ",
+		"motd":   "line one\nline two\\",
+		"region": "eu",
+	}
+	for _, format := range []string{"json", "env", "properties"} {
+		src := NewRegistry()
+		var banner, motd, region, token string
+		src.RegisterStringSetting("banner", "Banner", &banner)
+		src.RegisterStringSetting("motd", "Message of the day", &motd)
+		src.RegisterStringSetting("region", "Region", &region)
+		src.RegisterSecretSetting("token", "API token", &token)
+		if err := src.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+			t.Fatalf("setup failed: %v", err)
+		}
+		for name, value := range values {
+			if format == "env" && name == "motd" {
+				continue
+			}
+			if err := src.SetSetting(name, value); err != nil {
+				t.Fatalf("SetSetting failed: %v", err)
+			}
+		}
+		if err := src.SetSetting("token", "s3cret"); err != nil {
+			t.Fatalf("SetSetting failed: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := src.Export(&buf, ExportOptions{Format: format}); err != nil {
+			t.Fatalf("%s: Export failed: %v", format, err)
+		}
+		if strings.Contains(buf.String(), "s3cret") {
+			t.Fatalf("%s: sensitive value exported without IncludeSensitive: %s", format, buf.String())
+		}
+		ext := map[string]string{"json": ".json", "env": ".env", "properties": ".properties"}[format]
+		file := writeFile(t, filepath.Join(t.TempDir(), "settings"+ext), buf.String())
+
+		dst := NewRegistry()
+		var banner2, motd2, region2, token2 string
+		dst.RegisterStringSetting("banner", "Banner", &banner2)
+		dst.RegisterStringSetting("motd", "Message of the day", &motd2)
+		dst.RegisterStringSetting("region", "Region", &region2)
+		dst.RegisterSecretSetting("token", "API token", &token2)
+		if err := dst.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+			t.Fatalf("setup failed: %v", err)
+		}
+		if _, err := dst.Import(file, ImportOptions{}); err != nil {
+			t.Fatalf("%s: Import failed: %v", format, err)
+		}
+		if banner2 != banner || motd2 != motd || region2 != region || token2 != "" {
+			t.Fatalf("%s: round trip mismatch: %q %q %q %q\n%s", format, banner2, motd2, region2, token2, buf.String())
+		}
+	}
+}
+
+func TestExport_DefaultsAndHidden(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	port, internal := 8080, "x"
+	r.RegisterIntSetting("http.port", "HTTP port", &port)
+	r.RegisterSetting(&Setting{
+		Name:    "internal",
+		Hidden:  true,
+		GetFunc: func() string { return internal },
+		SetFunc: func(s string) error {
+			internal = s
+			return nil
+		},
+	})
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := r.Export(&buf, ExportOptions{}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if strings.TrimSpace(buf.String()) != "{}" {
+		t.Fatalf("expected only saved settings, got %s", buf.String())
+	}
+	buf.Reset()
+	if err := r.Export(&buf, ExportOptions{Format: "properties", IncludeDefaults: true, IncludeHidden: true}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if buf.String() != "http.port=8080\ninternal=x\n" {
+		t.Fatalf("unexpected export: %q", buf.String())
+	}
+}
+
+func TestImport_DryRunReplaceAndAtomicity(t *testing.T) {
+	r := NewRegistry()
+	port, region, zone := 8080, "", ""
+	r.RegisterIntSetting("http.port", "HTTP port", &port)
+	r.RegisterStringSetting("region", "Region", &region)
+	r.RegisterStringSetting("zone", "Zone", &zone)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.SetSetting("region", "eu"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if err := r.SetSetting("zone", "a"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	dir := t.TempDir()
+	file := writeFile(t, filepath.Join(dir, "prod.json"), `{"http.port": 9000, "region": "us"}`)
+
+	out := captureStdout(func() {
+		if err := (&SettingsImportCommand{File: file, DryRun: true, Replace: true}).Run(r); err != nil {
+			t.Errorf("dry run failed: %v", err)
+		}
+	})
+	for _, want := range []string{"+ http.port = 9000", "~ region: eu -> us", "- zone = a", "nothing saved"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("dry run output missing %q:\n%s", want, out)
+		}
+	}
+	if port != 8080 || region != "eu" || zone != "a" {
+		t.Fatalf("dry run changed values: %d %q %q", port, region, zone)
+	}
+
+	// The int setting's SetFunc rejects "fast", so nothing of the file may be saved or applied.
+	bad := writeFile(t, filepath.Join(dir, "bad.properties"), "region=ap\nhttp.port=fast\n")
+	if _, err := r.Import(bad, ImportOptions{}); err == nil {
+		t.Fatalf("expected import of an invalid value to fail")
+	}
+	if _, err := r.Import(writeFile(t, filepath.Join(dir, "unknown.env"), "nope=1\n"), ImportOptions{}); err == nil {
+		t.Fatalf("expected import of an unknown setting to fail")
+	}
+	if saved, _ := r.savedStates(r.store); region != "eu" || saved["region"].value != "eu" || saved["http.port"].saved {
+		t.Fatalf("failed import changed settings: region=%q saved=%v", region, saved)
+	}
+
+	captureStdout(func() {
+		if err := (&SettingsImportCommand{File: file, Replace: true, Reason: "promote"}).Run(r); err != nil {
+			t.Errorf("import failed: %v", err)
+		}
+	})
+	if port != 9000 || region != "us" || zone != "" {
+		t.Fatalf("import not applied: %d %q %q", port, region, zone)
+	}
+	if saved, _ := r.savedStates(r.store); saved["zone"].saved {
+		t.Fatalf("--replace kept zone: %v", saved)
+	}
+	entries, _ := r.History("region", time.Time{})
+	if last := entries[len(entries)-1]; last.Operation != OpImport || last.Reason != "promote" {
+		t.Fatalf("import not recorded in history: %#v", last)
+	}
+}
+
+func TestExportImport_ReplaceKeepsSecretsLeftOut(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var region, password string
+	r.RegisterStringSetting("region", "Region", &region)
+	r.RegisterSecretSetting("db.password", "Database password", &password)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.SetSettings(map[string]any{"region": "eu", "db.password": "hunter2"}); err != nil {
+		t.Fatalf("SetSettings failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := r.Export(&buf, ExportOptions{}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	file := writeFile(t, filepath.Join(t.TempDir(), "settings.json"), buf.String())
+	if err := r.SetSetting("region", "us"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+
+	changes, err := r.Import(file, ImportOptions{Replace: true})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Name != "region" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	saved, _ := r.savedStates(r.store)
+	if region != "eu" || password != "hunter2" || saved["db.password"].value != "hunter2" {
+		t.Fatalf("round trip with --replace lost values: region=%q password=%q saved=%v", region, password, saved)
+	}
+}
+
+func TestImport_RefusesSettingsWithoutParseFunc(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	mode := "fast"
+	r.RegisterSetting(&Setting{
+		Name:    "mode",
+		GetFunc: func() string { return mode },
+		SetFunc: func(s string) error {
+			if s == "bad" {
+				return errors.New("unsupported mode")
+			}
+			mode = s
+			return nil
+		},
+	})
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	file := writeFile(t, filepath.Join(t.TempDir(), "settings.json"), `{"mode": "bad"}`)
+	for _, dryRun := range []bool{true, false} {
+		if _, err := r.Import(file, ImportOptions{DryRun: dryRun}); err == nil || !strings.Contains(err.Error(), "ParseFunc") {
+			t.Fatalf("expected import (dry run %v) to be refused, got %v", dryRun, err)
+		}
+	}
+	entries, err := r.History("", time.Time{})
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(entries) != 0 || mode != "fast" {
+		t.Fatalf("refused import wrote rows or applied the value: %d entries, mode %q", len(entries), mode)
+	}
+}
@@ -0,0 +1,297 @@
+package app_settings
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Validator is a named rule a setting's string value must satisfy before it is applied or persisted.
+type Validator struct {
+	Rule string
+	Func func(value string) error
+}
+
+// ValidationError reports the setting and the rule a rejected value broke.
+type ValidationError struct {
+	Setting string
+	Rule    string
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("setting %s: rule %s: %v", e.Setting, e.Rule, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validate runs the setting's ParseFunc and validators against value and returns the first failure as a
+// *ValidationError, with the rule "type" for a value ParseFunc rejects. It never changes the setting; a
+// validator that panics fails with an error matching ErrHookPanic.
+func (s *Setting) Validate(value string) error {
+	if s.ParseFunc != nil {
+		if err := s.runValidator(Validator{Rule: "type", Func: s.ParseFunc}, value); err != nil {
+			return &ValidationError{Setting: s.Name, Rule: "type", Err: err}
+		}
+	}
+	for _, v := range s.Validators {
+		if err := s.runValidator(v, value); err != nil {
+			return &ValidationError{Setting: s.Name, Rule: v.Rule, Err: err}
+		}
+	}
+	return nil
+}
+
+func (s *Setting) runValidator(v Validator, value string) (err error) {
+	defer recoverHook(&err, s.Name, "validator "+v.Rule)
+	return v.Func(value)
+}
+
+// Option customizes a setting registered through one of the typed register helpers.
+type Option func(*Setting)
+
+func applyOptions(s *Setting, opts []Option) *Setting {
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithValidator adds a custom validation rule.
+func WithValidator(rule string, fn func(value string) error) Option {
+	return func(s *Setting) {
+		s.Validators = append(s.Validators, Validator{Rule: rule, Func: fn})
+	}
+}
+
+// Number is the set of types the Min, Max and Between options accept.
+type Number interface {
+	int | int8 | int16 | int32 | int64 | uint | uint8 | uint16 | uint32 | uint64 | float32 | float64 | time.Duration
+}
+
+// Min rejects values below minimum. Values are compared in the setting's own type, so Min(0) also bounds
+// float and duration settings.
+func Min[T Number](minimum T) Option {
+	low := boundRat("Min", minimum)
+	return numberRule[T](fmt.Sprintf("min=%v", minimum), func(value string, v *big.Rat) error {
+		if v.Cmp(low) < 0 {
+			return fmt.Errorf("%s is below the minimum %v", value, minimum)
+		}
+		return nil
+	})
+}
+
+// Max rejects values above maximum.
+func Max[T Number](maximum T) Option {
+	high := boundRat("Max", maximum)
+	return numberRule[T](fmt.Sprintf("max=%v", maximum), func(value string, v *big.Rat) error {
+		if v.Cmp(high) > 0 {
+			return fmt.Errorf("%s is above the maximum %v", value, maximum)
+		}
+		return nil
+	})
+}
+
+// Between rejects values outside [minimum, maximum].
+func Between[T Number](minimum, maximum T) Option {
+	low, high := boundRat("Between", minimum), boundRat("Between", maximum)
+	return numberRule[T](fmt.Sprintf("range=%v..%v", minimum, maximum), func(value string, v *big.Rat) error {
+		if v.Cmp(low) < 0 || v.Cmp(high) > 0 {
+			return fmt.Errorf("%s is outside %v..%v", value, minimum, maximum)
+		}
+		return nil
+	})
+}
+
+// numberRule adds a validator that checks values converted with the setting's own codec, falling back to
+// the codec of T for settings registered without one.
+func numberRule[T Number](rule string, check func(string, *big.Rat) error) Option {
+	fallback := codecNumber(numberCodec[T]())
+	return func(s *Setting) {
+		s.Validators = append(s.Validators, Validator{Rule: rule, Func: func(value string) error {
+			parse := s.number
+			if parse == nil {
+				parse = fallback
+			}
+			v, err := parse(value)
+			if err != nil {
+				return err
+			}
+			return check(value, v)
+		}})
+	}
+}
+
+// boundRat converts the bound of a number rule exactly. It panics if bound is NaN or infinite.
+func boundRat[T Number](option string, bound T) *big.Rat {
+	n, ok := numberRat(bound)
+	if !ok {
+		panic(fmt.Sprintf("app_settings: %s: bound %v is not a finite number", option, bound))
+	}
+	return n
+}
+
+// numberRat converts a value of one of the Number types exactly. It reports false for other types and for
+// NaN and infinite floats.
+func numberRat(value any) (*big.Rat, bool) {
+	n := new(big.Rat)
+	switch v := value.(type) {
+	case int:
+		return n.SetInt64(int64(v)), true
+	case int8:
+		return n.SetInt64(int64(v)), true
+	case int16:
+		return n.SetInt64(int64(v)), true
+	case int32:
+		return n.SetInt64(int64(v)), true
+	case int64:
+		return n.SetInt64(v), true
+	case time.Duration:
+		return n.SetInt64(int64(v)), true
+	case uint:
+		return n.SetUint64(uint64(v)), true
+	case uint8:
+		return n.SetUint64(uint64(v)), true
+	case uint16:
+		return n.SetUint64(uint64(v)), true
+	case uint32:
+		return n.SetUint64(uint64(v)), true
+	case uint64:
+		return n.SetUint64(v), true
+	case float32:
+		n = n.SetFloat64(float64(v))
+		return n, n != nil
+	case float64:
+		n = n.SetFloat64(v)
+		return n, n != nil
+	}
+	return nil, false
+}
+
+// codecNumber returns a function converting values to numbers with codec, for Setting.number, or nil when
+// T is not one of the Number types.
+func codecNumber[T any](codec Codec[T]) func(string) (*big.Rat, error) {
+	var zero T
+	if _, ok := numberRat(zero); !ok {
+		return nil
+	}
+	return func(s string) (*big.Rat, error) {
+		value, err := codec.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		n, ok := numberRat(value)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a finite number", s)
+		}
+		return n, nil
+	}
+}
+
+func numberCodec[T Number]() Codec[T] {
+	var codec any
+	switch any(*new(T)).(type) {
+	case int:
+		codec = IntCodec
+	case int8:
+		codec = Int8Codec
+	case int16:
+		codec = Int16Codec
+	case int32:
+		codec = Int32Codec
+	case int64:
+		codec = Int64Codec
+	case uint:
+		codec = UintCodec
+	case uint8:
+		codec = Uint8Codec
+	case uint16:
+		codec = Uint16Codec
+	case uint32:
+		codec = Uint32Codec
+	case uint64:
+		codec = Uint64Codec
+	case float32:
+		codec = Float32Codec
+	case float64:
+		codec = Float64Codec
+	case time.Duration:
+		codec = DurationCodec
+	}
+	return codec.(Codec[T])
+}
+
+// Pattern requires values to match the regular expression expr. It panics if expr does not compile.
+func Pattern(expr string) Option {
+	re := regexp.MustCompile(expr)
+	return WithValidator("pattern="+expr, func(value string) error {
+		if !re.MatchString(value) {
+			return fmt.Errorf("%q does not match %s", value, expr)
+		}
+		return nil
+	})
+}
+
+// MinLength requires values of at least n characters.
+func MinLength(n int) Option {
+	return WithValidator(fmt.Sprintf("minlength=%d", n), func(value string) error {
+		if l := utf8.RuneCountInString(value); l < n {
+			return fmt.Errorf("length %d is below the minimum %d", l, n)
+		}
+		return nil
+	})
+}
+
+// MaxLength allows values of at most n characters.
+func MaxLength(n int) Option {
+	return WithValidator(fmt.Sprintf("maxlength=%d", n), func(value string) error {
+		if l := utf8.RuneCountInString(value); l > n {
+			return fmt.Errorf("length %d is above the maximum %d", l, n)
+		}
+		return nil
+	})
+}
+
+// AllowedCIDRs requires IP values to fall inside one of the given networks. It panics if a CIDR is invalid.
+func AllowedCIDRs(cidrs ...string) Option {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(fmt.Sprintf("app_settings: AllowedCIDRs: %v", err))
+		}
+		nets = append(nets, n)
+	}
+	return WithValidator("cidr="+strings.Join(cidrs, ","), func(value string) error {
+		ip, err := IPCodec.Parse(value)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(nets, func(n *net.IPNet) bool { return n.Contains(ip) }) {
+			return fmt.Errorf("%s is not inside %s", ip, strings.Join(cidrs, ", "))
+		}
+		return nil
+	})
+}
+
+// AllowedSchemes requires URL values to use one of the given schemes.
+func AllowedSchemes(schemes ...string) Option {
+	return WithValidator("scheme="+strings.Join(schemes, ","), func(value string) error {
+		u, err := url.Parse(value)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(schemes, func(s string) bool { return strings.EqualFold(s, u.Scheme) }) {
+			return fmt.Errorf("scheme %q is not one of %s", u.Scheme, strings.Join(schemes, ", "))
+		}
+		return nil
+	})
+}
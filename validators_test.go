@@ -0,0 +1,107 @@
+package app_settings
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestValidators_RejectBeforeApplyAndPersist(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	port := 8080
+	timeout := time.Second
+	name := "svc"
+	ip := net.ParseIP("10.0.0.1")
+	endpoint := url.URL{Scheme: "https", Host: "example.com"}
+	r.RegisterIntSetting("http.port", "HTTP port", &port, Between(1, 65535))
+	r.RegisterDurationSetting("timeout", "Timeout", &timeout, Min(time.Duration(0)))
+	r.RegisterStringSetting("name", "Name", &name, Pattern(`^[a-z]+$`), MaxLength(8))
+	r.RegisterIPSetting("bind", "Bind address", &ip, AllowedCIDRs("10.0.0.0/8", "127.0.0.0/8"))
+	r.RegisterURLSetting("endpoint", "Endpoint", &endpoint, AllowedSchemes("https"))
+	level := RegisterIn(r, "level", "Level", 3, IntCodec, WithValidator("odd", func(v string) error {
+		if v == "2" || v == "4" {
+			return errors.New("must be odd")
+		}
+		return nil
+	}))
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	rejected := []struct {
+		setting, value, rule string
+	}{
+		{"http.port", "0", "range=1..65535"},
+		{"timeout", "-1s", "min=0s"},
+		{"name", "Svc", "pattern=^[a-z]+$"},
+		{"name", "abcdefghi", "maxlength=8"},
+		{"bind", "192.168.1.1", "cidr=10.0.0.0/8,127.0.0.0/8"},
+		{"endpoint", "http://example.com", "scheme=https"},
+		{"level", "4", "odd"},
+	}
+	for _, tc := range rejected {
+		err := r.SetSetting(tc.setting, tc.value)
+		var verr *ValidationError
+		if !errors.As(err, &verr) || verr.Rule != tc.rule || verr.Setting != tc.setting {
+			t.Fatalf("%s=%s: expected rule %q to fail, got %v", tc.setting, tc.value, tc.rule, err)
+		}
+	}
+	if err := (&SettingsSaveCommand{Setting: "http.port", Value: "70000"}).Run(r); err == nil {
+		t.Fatalf("expected CLI save to be rejected")
+	}
+	if port != 8080 || timeout != time.Second || name != "svc" || level.Get() != 3 {
+		t.Fatalf("rejected values must not be applied")
+	}
+	rows, err := r.store.AppSetting.Find()
+	if err != nil {
+		t.Fatalf("db find failed: %v", err)
+	}
+	if len(rows) != 0 {
+		t.Fatalf("rejected values must not be persisted: %#v", rows)
+	}
+
+	if err := r.SetSetting("http.port", 443); err != nil {
+		t.Fatalf("valid value rejected: %v", err)
+	}
+	if err := r.SetSetting("bind", "127.0.0.1"); err != nil {
+		t.Fatalf("valid IP rejected: %v", err)
+	}
+}
+
+func TestValidators_NumberRulesUseSettingType(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	ratio := 0.25
+	timeout := time.Second
+	r.RegisterFloatSetting("ratio", "Ratio", &ratio, Min(0), Max(1))
+	r.RegisterDurationSetting("timeout", "Timeout", &timeout, Min(0))
+	small := RegisterIn(r, "small", "Small", float32(0.5), Float32Codec, Between(float32(0.1), 1))
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	if err := r.SetSetting("ratio", 0.5); err != nil || ratio != 0.5 {
+		t.Fatalf("Min(0) on a float setting rejected 0.5: %v", err)
+	}
+	if err := r.SetSetting("timeout", "5s"); err != nil || timeout != 5*time.Second {
+		t.Fatalf("Min(0) on a duration setting rejected 5s: %v", err)
+	}
+	if err := small.Set(0.1); err != nil {
+		t.Fatalf("float32 bound not compared as float32: %v", err)
+	}
+	for _, tc := range []struct {
+		setting, value, rule string
+	}{
+		{"ratio", "-0.5", "min=0"},
+		{"ratio", "1.5", "max=1"},
+		{"timeout", "-1s", "min=0"},
+	} {
+		var verr *ValidationError
+		if err := r.SetSetting(tc.setting, tc.value); !errors.As(err, &verr) || verr.Rule != tc.rule {
+			t.Fatalf("%s=%s: expected rule %q to fail, got %v", tc.setting, tc.value, tc.rule, err)
+		}
+	}
+}
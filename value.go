@@ -0,0 +1,95 @@
+package app_settings
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Value is a typed handle to a registered setting. Get is an atomic load and every write path (SetSetting,
+// the settings CLI and reloads) stores atomically, so a Value can be read from any goroutine without a
+// data race.
+type Value[T any] struct {
+	current  atomic.Pointer[T]
+	registry *Registry
+	name     string
+	codec    Codec[T]
+}
+
+// Get returns the current value.
+func (v *Value[T]) Get() T {
+	return *v.current.Load()
+}
+
+// Set validates, applies and persists a new value. It is SetSetting for this handle.
+func (v *Value[T]) Set(value T) error {
+	return v.registry.SetSetting(v.name, value)
+}
+
+// SetContext is Set with a context. It is SetSettingContext for this handle.
+func (v *Value[T]) SetContext(ctx context.Context, value T) error {
+	return v.registry.SetSettingContext(ctx, v.name, value)
+}
+
+// Name returns the setting name the handle was registered under.
+func (v *Value[T]) Name() string {
+	return v.name
+}
+
+func (v *Value[T]) store(value T) {
+	v.current.Store(&value)
+}
+
+// Register registers a typed setting on the default registry. See RegisterIn.
+func Register[T any](name, description string, defaultValue T, codec Codec[T], opts ...Option) *Value[T] {
+	return RegisterIn(defaultRegistry, name, description, defaultValue, codec, opts...)
+}
+
+// RegisterIn registers a setting of type T that starts at defaultValue and is converted to and from its
+// stored form with codec. The returned handle is safe for concurrent use.
+func RegisterIn[T any](r *Registry, name, description string, defaultValue T, codec Codec[T], opts ...Option) *Value[T] {
+	v := &Value[T]{registry: r, name: name, codec: codec}
+	v.store(defaultValue)
+	r.RegisterSetting(applyOptions(&Setting{
+		Name:              name,
+		Description:       description,
+		ValueToStringFunc: codecValueToString(codec),
+		ParseFunc:         codecParseFunc(codec),
+		number:            codecNumber(codec),
+		GetFunc:           func() string { return codec.Format(v.Get()) },
+		SetFunc: func(s string) error {
+			value, err := codec.Parse(s)
+			if err != nil {
+				return err
+			}
+			v.store(value)
+			return nil
+		},
+	}, opts))
+	return v
+}
+
+// registerPointer registers a setting that reads and writes *prop directly. It backs the pointer-based
+// Register*Setting helpers, which are kept for compatibility; unlike a Value, the pointer is not
+// synchronized, so prefer Register when the setting is read concurrently with writes.
+func registerPointer[T any](r *Registry, name, description string, prop *T, codec Codec[T], opts ...Option) {
+	r.RegisterSetting(applyOptions(newPointerSetting(name, description, prop, codec), opts))
+}
+
+func newPointerSetting[T any](name, description string, prop *T, codec Codec[T]) *Setting {
+	return &Setting{
+		Name:              name,
+		Description:       description,
+		ValueToStringFunc: codecValueToString(codec),
+		ParseFunc:         codecParseFunc(codec),
+		number:            codecNumber(codec),
+		GetFunc:           func() string { return codec.Format(*prop) },
+		SetFunc: func(s string) error {
+			value, err := codec.Parse(s)
+			if err != nil {
+				return err
+			}
+			*prop = value
+			return nil
+		},
+	}
+}
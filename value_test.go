@@ -0,0 +1,113 @@
+package app_settings
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegister_ValueIsUpdatedByEveryWritePath(t *testing.T) {
+	r := NewRegistry()
+	port := RegisterIn(r, "http.port", "HTTP port", 8080, IntCodec)
+	if got := port.Get(); got != 8080 {
+		t.Fatalf("expected default 8080, got %d", got)
+	}
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if got := r.SettingsVars()["http.port"]; got != "8080" {
+		t.Fatalf("unexpected formatted default %q", got)
+	}
+
+	if err := port.Set(9000); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if got := port.Get(); got != 9000 {
+		t.Fatalf("expected 9000 after Set, got %d", got)
+	}
+	captureStdout(func() {
+		if err := (&SettingsSaveCommand{Setting: "http.port", Value: "9100"}).Run(r); err != nil {
+			t.Errorf("save command failed: %v", err)
+		}
+	})
+	if got := port.Get(); got != 9100 {
+		t.Fatalf("expected 9100 after CLI save, got %d", got)
+	}
+	if err := r.SetSetting("http.port", "not-a-number"); err == nil {
+		t.Fatalf("expected parse error")
+	}
+	if got := port.Get(); got != 9100 {
+		t.Fatalf("failed parse must not change the value, got %d", got)
+	}
+}
+
+func TestRegister_ConcurrentReadsAndWrites(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	timeout := RegisterIn(r, "timeout", "Request timeout", time.Second, DurationCodec)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				if timeout.Get() <= 0 {
+					t.Errorf("unexpected timeout %v", timeout.Get())
+				}
+			}
+		}
+	}()
+	for i := 1; i <= 20; i++ {
+		if err := r.SetSetting("timeout", time.Duration(i)*time.Millisecond); err != nil {
+			t.Fatalf("SetSetting failed: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	if got := timeout.Get(); got != 20*time.Millisecond {
+		t.Fatalf("expected 20ms, got %v", got)
+	}
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	t.Parallel()
+	roundTrip := func(name string, format func() (string, error), want string) {
+		t.Helper()
+		got, err := format()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got != want {
+			t.Fatalf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+	roundTrip("uint16", func() (string, error) {
+		v, err := Uint16Codec.Parse("65535")
+		return Uint16Codec.Format(v), err
+	}, "65535")
+	roundTrip("ipnet", func() (string, error) {
+		v, err := IPNetCodec.Parse("10.0.0.0/8")
+		return IPNetCodec.Format(v), err
+	}, "10.0.0.0/8")
+	roundTrip("slice", func() (string, error) {
+		v, err := StringSliceCodec.Parse("a,b")
+		return StringSliceCodec.Format(v), err
+	}, "a,b")
+	if _, err := Int8Codec.Parse("300"); err == nil {
+		t.Fatalf("expected int8 overflow error")
+	}
+	if _, err := CronCodec.Parse("not cron"); err == nil {
+		t.Fatalf("expected cron error")
+	}
+	if v, err := IPCodec.Parse("127.0.0.1"); err != nil || !v.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("unexpected IP parse: %v %v", v, err)
+	}
+}
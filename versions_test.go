@@ -0,0 +1,148 @@
+package app_settings
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSetSettingIfUnchanged_DetectsConcurrentWriters(t *testing.T) {
+	t.Parallel()
+	path := tempDBPath(t)
+	first, second := NewRegistry(), NewRegistry()
+	var a, b string
+	first.RegisterStringSetting("region", "Region", &a)
+	second.RegisterStringSetting("region", "Region", &b)
+	for _, r := range []*Registry{first, second} {
+		if err := r.Setup(path, SettingsOptions{}); err != nil {
+			t.Fatalf("setup failed: %v", err)
+		}
+	}
+
+	if err := first.SetSettingIfUnchanged("region", 0, "eu"); err != nil {
+		t.Fatalf("create with version 0 failed: %v", err)
+	}
+	version, err := first.SavedVersion("region")
+	if err != nil || version == 0 {
+		t.Fatalf("expected a version, got %d (%v)", version, err)
+	}
+	if err := second.SetSettingIfUnchanged("region", 0, "us"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict for a value created concurrently, got %v", err)
+	}
+
+	// Both writers read the same version; only the first save wins.
+	if err := first.SetSettingIfUnchanged("region", version, "ap"); err != nil {
+		t.Fatalf("SetSettingIfUnchanged failed: %v", err)
+	}
+	err = second.SetSettingIfUnchanged("region", version, "us")
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Expected != version || conflict.Actual <= version {
+		t.Fatalf("expected a ConflictError, got %v", err)
+	}
+	if b == "us" {
+		t.Fatalf("losing write was applied in memory")
+	}
+	saved, _ := second.savedStates(second.store)
+	if saved["region"].value != "ap" {
+		t.Fatalf("losing write was saved: %v", saved)
+	}
+
+	entries, _ := first.History("region", time.Time{})
+	if latest, _ := first.SavedVersion("region"); latest != entries[len(entries)-1].ID {
+		t.Fatalf("expected the version to be the latest history entry, got %d", latest)
+	}
+}
+
+func TestSettingsSave_IfVersion(t *testing.T) {
+	r := NewRegistry()
+	region := ""
+	r.RegisterStringSetting("region", "Region", &region)
+	if err := r.Setup(tempDBPath(t), SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := r.SetSetting("region", "eu"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	version, _ := r.SavedVersion("region")
+	out := captureStdout(func() {
+		_ = (&SettingsListSavedCommand{}).Run(r)
+	})
+	if !strings.Contains(out, "VERSION") || !strings.Contains(out, strconv.FormatInt(version, 10)) {
+		t.Fatalf("saved listing does not show the version: %s", out)
+	}
+
+	var cli struct {
+		Settings SettingsCommand `cmd:""`
+	}
+	cli.Settings.Registry = r
+	parser, err := kong.New(&cli, kong.Exit(func(int) {}))
+	if err != nil {
+		t.Fatalf("kong.New failed: %v", err)
+	}
+	stale := strconv.FormatInt(version-1, 10)
+	ctx, err := parser.Parse([]string{"settings", "save", "region", "us", "--if-version", stale})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	captureStdout(func() {
+		if err := ctx.Run(); !errors.Is(err, ErrConflict) {
+			t.Errorf("expected ErrConflict, got %v", err)
+		}
+	})
+	if region != "eu" {
+		t.Fatalf("conflicting save changed the value to %q", region)
+	}
+	ctx, err = parser.Parse([]string{"settings", "save", "region", "us", "--if-version", strconv.FormatInt(version, 10)})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	captureStdout(func() {
+		if err := ctx.Run(); err != nil {
+			t.Errorf("save --if-version failed: %v", err)
+		}
+	})
+	if region != "us" {
+		t.Fatalf("save --if-version not applied, region=%q", region)
+	}
+}
+
+func TestSetSettingIfUnchanged_RacingCreateIsAConflict(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	var region string
+	r.RegisterStringSetting("region", "Region", &region)
+	gormDB, err := gorm.Open(sqlite.Open(tempDBPath(t)), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db failed: %v", err)
+	}
+	if err := r.SetupWithDB(gormDB, SettingsOptions{}); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	// Another writer creates the row after this one found none and before it inserts its own.
+	raced := false
+	err = gormDB.Callback().Create().Before("gorm:create").Register("test:race", func(tx *gorm.DB) {
+		if raced || tx.Statement.Table != DefaultTableName {
+			return
+		}
+		raced = true
+		tx.Exec("INSERT INTO app_settings (key, value, version) VALUES ('region', 'us', 999)")
+	})
+	if err != nil {
+		t.Fatalf("register callback failed: %v", err)
+	}
+
+	err = r.SetSettingIfUnchanged("region", 0, "eu")
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) || conflict.Actual != 999 {
+		t.Fatalf("expected a ConflictError for the racing create, got %v", err)
+	}
+	if region == "eu" {
+		t.Fatalf("losing write was applied in memory")
+	}
+}